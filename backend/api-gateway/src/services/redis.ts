@@ -1,7 +1,8 @@
 import Redis from 'ioredis';
 
 // Redis queue name - shared between Producer (API) and Consumer (Worker)
-export const SUBMISSION_QUEUE = 'submission_queue';
+// (SUBMISSION_QUEUE must match the execution worker's)
+export const SUBMISSION_QUEUE = process.env.SUBMISSION_QUEUE || 'submission_queue';
 
 let redisClient: Redis | null = null;
 
@@ -6,7 +6,7 @@ import { z } from 'zod';
  */
 
 // Supported languages for code execution
-export const SupportedLanguages = ['python', 'javascript', 'cpp'] as const;
+export const SupportedLanguages = ['python', 'javascript', 'cpp', 'rust', 'bash'] as const;
 export type SupportedLanguage = (typeof SupportedLanguages)[number];
 
 // Zod schema for validating incoming submission requests
@@ -34,6 +34,26 @@ export interface Job {
   language: SupportedLanguage;
   code: string;
   submittedAt: string; // ISO 8601 timestamp
+  files?: Record<string, string>; // Multi-file submissions: relative path -> content
+  entryPoint?: string; // File (or Python package) to run instead of script.<ext>
+  profile?: string; // Named resource profile (default "small"); larger ones for trusted callers only
+  seed?: number; // Exposed to the program as RCE_SEED for deterministic runs
+  callbackUrl?: string; // Receives the final result as a POST (host must be allowlisted)
+  artifactPaths?: string[]; // Patterns (e.g. "*.png") for files written to /output to store in GridFS
+  stdin?: string; // Program input
+  stdinRef?: string; // Redis key ("stdin:..." by default) holding a large input, instead of stdin
+  stdinParts?: string[]; // Input in pieces the program reads concatenated, instead of stdin
+  useHarness?: boolean; // Wrap code in the language's harness (submit only a solution function)
+  mode?: 'run' | 'eval'; // "eval" also returns the value of the code's last expression (Python, JavaScript)
+  setupScript?: string; // sh script run before the code, e.g. to seed fixtures under /tmp
+  teardownScript?: string; // sh script run after the code; failures are only logged
+  stopOnOutput?: boolean; // Kill the program at its first non-empty stdout line
+  outputEncoding?: 'utf8' | 'base64'; // How output is returned; base64 for binary-producing programs
+  deadlineUnixMs?: number; // Absolute deadline; the worker marks the job "expired" if it's already passed
+  metadata?: Record<string, string>; // Opaque caller data (e.g. correlation IDs) echoed with the result
+  analysisTypes?: string[]; // Analyses to route the finished job to (worker ANALYSIS_ROUTES); all if unset
+  variant?: string; // A/B variant of limits or images (worker VARIANTS), assigned upstream; DEFAULT_VARIANT if unset
+  retryCount?: number; // Set by the worker when it requeues a job after a transient failure
 }
 
 // MongoDB document structure (extends Job with status tracking)
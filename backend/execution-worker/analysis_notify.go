@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ============================================
+// Asynchronous Analysis Notifications
+// ============================================
+// Notifying the analysis worker (see notifyAnalysisWorker) is the last
+// step of processJob, after the result is stored and published. So it
+// doesn't hold up the worker loop's next job, notifications are handed
+// to a pool of ANALYSIS_NOTIFY_WORKERS goroutines (default 2) through a
+// queue of ANALYSIS_NOTIFY_QUEUE_SIZE (default 100).
+//
+// When the queue is full, ANALYSIS_NOTIFY_OVERFLOW decides:
+//
+//   - drop (default): the notification is dropped at once
+//   - block: processJob waits up to ANALYSIS_NOTIFY_BLOCK_TIMEOUT
+//     (default 1s) for room, then drops it
+//
+// Dropped notifications are logged and counted in
+// rce_analysis_notifications_dropped_total. The analysis worker misses
+// those jobs, as it would any notification published while it's down.
+//
+// On shutdown, once the worker loop has stopped, the queued
+// notifications are still published before Redis is closed.
+//
+// ANALYSIS_NOTIFY_WORKERS=0 notifies synchronously in processJob instead.
+// ============================================
+
+var (
+	analysisNotifyWorkers      = getEnvInt("ANALYSIS_NOTIFY_WORKERS", 2)
+	analysisNotifyQueueSize    = getEnvInt("ANALYSIS_NOTIFY_QUEUE_SIZE", 100)
+	analysisNotifyOverflow     = getEnv("ANALYSIS_NOTIFY_OVERFLOW", "drop")
+	analysisNotifyBlockTimeout = getEnvDuration("ANALYSIS_NOTIFY_BLOCK_TIMEOUT", 1*time.Second)
+)
+
+var analysisNotificationsDropped = newCounter("rce_analysis_notifications_dropped_total", "Analysis notifications dropped because the notification queue was full")
+
+// analysisNotifyTimeout bounds publishing one notification
+const analysisNotifyTimeout = 5 * time.Second
+
+// analysisNotification is one finished job waiting to be announced
+type analysisNotification struct {
+	job    Job
+	result *ExecutionResult
+}
+
+// analysisNotifier is the notification pool, started by startAnalysisNotifier
+type analysisNotifier struct {
+	mu     sync.RWMutex // Held for writing only to close queue
+	closed bool
+	queue  chan analysisNotification
+	done   chan struct{}
+}
+
+// notifier is the process-wide pool; nil notifies synchronously
+var notifier *analysisNotifier
+
+// validateAnalysisNotify checks the notification pool configuration
+func validateAnalysisNotify() error {
+	if analysisNotifyOverflow != "drop" && analysisNotifyOverflow != "block" {
+		return fmt.Errorf("ANALYSIS_NOTIFY_OVERFLOW must be drop or block, got %q", analysisNotifyOverflow)
+	}
+	if analysisNotifyWorkers > 0 && analysisNotifyQueueSize < 1 {
+		return fmt.Errorf("ANALYSIS_NOTIFY_QUEUE_SIZE must be at least 1")
+	}
+	return nil
+}
+
+// startAnalysisNotifier starts the notification pool, unless notifications are synchronous
+func startAnalysisNotifier() *analysisNotifier {
+	if analysisNotifyWorkers <= 0 {
+		log.Println("📊 Analysis notifications are synchronous (ANALYSIS_NOTIFY_WORKERS=0)")
+		return nil
+	}
+
+	n := &analysisNotifier{
+		queue: make(chan analysisNotification, analysisNotifyQueueSize),
+		done:  make(chan struct{}),
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < analysisNotifyWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for notification := range n.queue {
+				ctx, cancel := context.WithTimeout(context.Background(), analysisNotifyTimeout)
+				sendAnalysisNotification(ctx, notification.job, notification.result)
+				cancel()
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(n.done)
+	}()
+
+	log.Printf("📊 Analysis notifier started (%d workers, queue %d, overflow %s)", analysisNotifyWorkers, analysisNotifyQueueSize, analysisNotifyOverflow)
+	return n
+}
+
+// enqueueAnalysisNotification announces a finished job to the analysis
+// worker, through the pool if there is one
+func enqueueAnalysisNotification(ctx context.Context, job Job, result *ExecutionResult) {
+	n := notifier
+	if n == nil {
+		sendAnalysisNotification(ctx, job, result)
+		return
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		sendAnalysisNotification(ctx, job, result) // Shutting down: nobody is left to drain the queue
+		return
+	}
+
+	notification := analysisNotification{job: job, result: result}
+	select {
+	case n.queue <- notification:
+		return
+	default:
+	}
+
+	if analysisNotifyOverflow == "block" {
+		timer := time.NewTimer(analysisNotifyBlockTimeout)
+		defer timer.Stop()
+		select {
+		case n.queue <- notification:
+			return
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	log.Printf("⚠️  [%s] Analysis notification queue full, dropping notification", job.JobID)
+	analysisNotificationsDropped.Inc()
+}
+
+// sendAnalysisNotification notifies the analysis worker and logs the outcome
+func sendAnalysisNotification(ctx context.Context, job Job, result *ExecutionResult) {
+	if err := notifyAnalysisWorker(ctx, job, result); err != nil {
+		log.Printf("⚠️ Failed to notify analysis worker: %v", err)
+		// Non-fatal error - execution succeeded
+		return
+	}
+	log.Printf("📊 Job [%s] sent to analysis queue", job.JobID)
+}
+
+// Stop publishes the queued notifications and waits for the pool, for
+// shutdown. Must be called once the worker loop has stopped.
+func (n *analysisNotifier) Stop(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+	return waitFor(ctx, n.done)
+}
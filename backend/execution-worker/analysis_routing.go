@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// ============================================
+// Analysis Routing
+// ============================================
+// By default every finished job is published to the one ANALYSIS_CHANNEL.
+// With several specialized analysis workers (complexity, style,
+// plagiarism, ...), ANALYSIS_ROUTES instead fans jobs out to a channel
+// per analysis type, so no worker has to re-filter everything:
+//
+//   ANALYSIS_ROUTES="complexity=analysis:complexity;style=analysis:style@python|javascript"
+//
+// Each route is type=channel, optionally restricted to languages with
+// @lang1|lang2. A job is published to every route whose languages
+// include it and whose type is in the job's AnalysisTypes; a job with no
+// AnalysisTypes goes to every route for its language. A channel shared
+// by several matching routes is published to once.
+// ============================================
+
+// analysisRoute sends one analysis type to its channel
+type analysisRoute struct {
+	analysisType string
+	channel      string
+	languages    []string // Empty matches every language
+}
+
+// analysisRoutes is parsed from ANALYSIS_ROUTES by loadAnalysisRoutes; empty means single-channel mode
+var analysisRoutes []analysisRoute
+
+// loadAnalysisRoutes parses and validates ANALYSIS_ROUTES
+func loadAnalysisRoutes() error {
+	spec := getEnv("ANALYSIS_ROUTES", "")
+	if strings.TrimSpace(spec) == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		analysisType, target, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid route %q (expected type=channel[@lang|lang])", entry)
+		}
+		channel, languages, _ := strings.Cut(target, "@")
+		route := analysisRoute{
+			analysisType: strings.TrimSpace(analysisType),
+			channel:      strings.TrimSpace(channel),
+		}
+		if route.analysisType == "" || route.channel == "" {
+			return fmt.Errorf("invalid route %q (type and channel must not be empty)", entry)
+		}
+		if languages != "" {
+			for _, language := range strings.Split(languages, "|") {
+				language = canonicalLanguage(strings.TrimSpace(language))
+				if !IsLanguageSupported(language) {
+					return fmt.Errorf("route %q: %q is not a supported language", entry, language)
+				}
+				route.languages = append(route.languages, language)
+			}
+		}
+
+		analysisRoutes = append(analysisRoutes, route)
+		log.Printf("📊 Analysis route: %s -> %s (languages: %s)", route.analysisType, route.channel, describeLanguages(route.languages))
+	}
+	return nil
+}
+
+// analysisChannels returns the channels a finished job is published to
+func analysisChannels(job Job) []string {
+	if len(analysisRoutes) == 0 {
+		return []string{analysisChannel}
+	}
+
+	var channels []string
+	for _, route := range analysisRoutes {
+		if len(route.languages) > 0 && !slices.Contains(route.languages, job.Language) {
+			continue
+		}
+		if len(job.AnalysisTypes) > 0 && !slices.Contains(job.AnalysisTypes, route.analysisType) {
+			continue
+		}
+		if !slices.Contains(channels, route.channel) {
+			channels = append(channels, route.channel)
+		}
+	}
+	return channels
+}
+
+// describeLanguages formats a route's languages for logs
+func describeLanguages(languages []string) string {
+	if len(languages) == 0 {
+		return "all"
+	}
+	return strings.Join(languages, ", ")
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================
+// Result Artifacts
+// ============================================
+// A job that sets ArtifactPaths gets a writable /output directory. After
+// the program exits, files in it matching any of the patterns (path.Match
+// syntax, relative to /output, e.g. "*.png" or "plots/*.csv") are read
+// back and stored in the "artifacts" GridFS bucket as "<jobId>/<name>",
+// and their names are returned in the result.
+//
+// /output is the job's execution directory subpath "output" on the shared
+// volume, mounted read-write (requires Docker API 1.45+ for subpaths).
+//
+// Limits:
+//   MAX_ARTIFACT_BYTES - total size of a job's stored artifacts (default 5MB)
+// Files past the limit are skipped, not truncated.
+// ============================================
+
+const (
+	artifactDirName    = "output"    // Inside the execution directory
+	artifactMountPath  = "/output"   // Inside the container
+	artifactBucketName = "artifacts" // GridFS bucket
+)
+
+var maxArtifactBytes = int64(getEnvInt("MAX_ARTIFACT_BYTES", 5*1024*1024))
+
+// Artifact is a file the program produced in /output
+type Artifact struct {
+	Name string // Path relative to /output
+	Data []byte
+}
+
+// prepareArtifactDir creates the job's output directory, writable by the
+// unprivileged container user
+func prepareArtifactDir(execDir string) (string, error) {
+	dir := filepath.Join(execDir, artifactDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	// Chmod explicitly: MkdirAll is subject to the umask
+	if err := os.Chmod(dir, 0777); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// collectArtifacts reads the files in dir that match any of patterns,
+// stopping at MAX_ARTIFACT_BYTES. Symlinks and special files are ignored
+// so the program can't point the worker at files outside /output.
+func collectArtifacts(dir string, patterns []string, jobID string) []Artifact {
+	var artifacts []Artifact
+	var total int64
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if !matchesAnyPattern(name, patterns) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if total+info.Size() > maxArtifactBytes {
+			log.Printf("⚠️  [%s] Skipping artifact %s: total artifact size limit (%d bytes) reached", jobID, name, maxArtifactBytes)
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			log.Printf("⚠️  [%s] Failed to read artifact %s: %v", jobID, name, err)
+			return nil
+		}
+		if total+int64(len(data)) > maxArtifactBytes {
+			// Grew between stat and read
+			return nil
+		}
+
+		total += int64(len(data))
+		artifacts = append(artifacts, Artifact{Name: name, Data: data})
+		return nil
+	})
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to collect artifacts: %v", jobID, err)
+	}
+
+	return artifacts
+}
+
+// matchesAnyPattern reports whether name matches one of the patterns
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateArtifactPatterns rejects malformed patterns before a container is started
+func validateArtifactPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid artifact pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// storeArtifacts uploads a job's artifacts to GridFS and returns the
+// names of those stored
+func storeArtifacts(ctx context.Context, jobID string, artifacts []Artifact) ([]string, error) {
+	if len(artifacts) == 0 {
+		return nil, nil
+	}
+
+	bucket, err := gridfs.NewBucket(mongoDb, options.GridFSBucket().SetName(artifactBucketName))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		opts := options.GridFSUpload().SetMetadata(bson.M{"jobId": jobID, "name": artifact.Name})
+		if _, err := bucket.UploadFromStream(jobID+"/"+artifact.Name, bytes.NewReader(artifact.Data), opts); err != nil {
+			return names, fmt.Errorf("failed to store artifact %s: %w", artifact.Name, err)
+		}
+		names = append(names, artifact.Name)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ============================================
+// Worker Version and Config Hash
+// ============================================
+// During a rolling deploy the fleet runs several builds and configs at
+// once. To tie an odd result to the worker that produced it, every
+// stored result records:
+//
+//   - workerVersion: the worker's version (set at build time with
+//     -ldflags "-X main.version=...")
+//   - configHash: a short SHA-256 of the effective configuration - the
+//     language map, resource profiles and variants after all overrides,
+//     plus every environment variable the worker read at startup
+//
+// Two workers with the same hash run with the same configuration. The
+// hash is computed once, after startup configuration is loaded; values
+// only go into the hash, never into the result.
+// ============================================
+
+var (
+	envReadMu sync.Mutex
+	envRead   = make(map[string]*string) // Key -> value, nil if unset
+)
+
+// configHash is the effective configuration's hash, set by computeConfigHash
+var configHash string
+
+// lookupEnv reads an environment variable, recording it for the config hash
+func lookupEnv(key string) (string, bool) {
+	value, exists := os.LookupEnv(key)
+
+	envReadMu.Lock()
+	if exists {
+		envRead[key] = &value
+	} else {
+		envRead[key] = nil
+	}
+	envReadMu.Unlock()
+	return value, exists
+}
+
+// computeConfigHash hashes the effective configuration into configHash.
+// Must be called once startup configuration has been loaded.
+func computeConfigHash() {
+	envReadMu.Lock()
+	keys := make([]string, 0, len(envRead))
+	for key := range envRead {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	env := make([][2]string, 0, len(keys))
+	for _, key := range keys {
+		if value := envRead[key]; value != nil {
+			env = append(env, [2]string{key, *value})
+		}
+	}
+	envReadMu.Unlock()
+
+	// encoding/json sorts map keys, so equal configs hash equally
+	data, err := json.Marshal(struct {
+		Env       [][2]string
+		Languages map[string]LanguageConfig
+		Profiles  map[string]ResourceProfile
+		Variants  map[string]Variant
+	}{env, languageMap, resourceProfiles, variants})
+	if err != nil {
+		log.Printf("⚠️  Failed to hash configuration: %v", err)
+		return
+	}
+	sum := sha256.Sum256(data)
+	configHash = hex.EncodeToString(sum[:])[:16]
+	log.Printf("🔖 Worker v%s, config hash %s", version, configHash)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+)
+
+// ============================================
+// Code Delivery
+// ============================================
+// By default (CODE_DELIVERY=file) the code is written to the shared
+// execution volume and mounted read-only into the container. With
+// CODE_DELIVERY=stdin it is instead piped to the interpreter over the
+// container's stdin (e.g. `python3 -`), and the volume isn't mounted
+// at all, so nothing the job submits touches a shared filesystem.
+//
+// Stdin can only carry one thing, so a job falls back to a file when:
+//   - its language has no StdinArgs (e.g. compiled languages)
+//   - it has its own stdin input, several files or an entrypoint
+//   - it uses a harness, eval mode or artifacts
+//   - its language has a preprocessor (see preprocessors.go)
+//
+// Piped code has no file name: tracebacks refer to "<stdin>" (Python)
+// or "[stdin]" (Node) instead of the script path, and the program runs
+// in /tmp.
+// ============================================
+
+var codeDelivery = getEnv("CODE_DELIVERY", "file")
+
+// stdinEntryName stands in for the entrypoint of piped code in logs
+const stdinEntryName = "<stdin>"
+
+// validateCodeDelivery checks CODE_DELIVERY
+func validateCodeDelivery() error {
+	if codeDelivery != "file" && codeDelivery != "stdin" {
+		return fmt.Errorf("CODE_DELIVERY must be file or stdin, got %q", codeDelivery)
+	}
+	return nil
+}
+
+// useStdinDelivery reports whether the job's code is piped in rather than written to a file
+func useStdinDelivery(job Job, langConfig LanguageConfig) bool {
+	if codeDelivery != "stdin" || len(langConfig.StdinArgs) == 0 || lintEnabled(langConfig) {
+		return false
+	}
+	return job.Stdin == "" && len(job.Files) == 0 && job.EntryPoint == "" &&
+		!job.UseHarness && job.Mode != modeEval && len(job.ArtifactPaths) == 0
+}
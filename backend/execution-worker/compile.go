@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// ============================================
+// Compiled Languages
+// ============================================
+// A Compiled language builds and runs the submission in the same
+// container: its EntrypointTemplate (from compileAndRun) runs the
+// compiler, writes the binary to the container's /tmp and execs it. The
+// compile counts against the job's resource limits.
+//
+// The compile has its own CompileTimeout (COMPILE_TIMEOUT_<LANG>
+// overrides it, 0 disables), enforced inside the container. A compile
+// that runs past it is reported as "compile_timeout". The job's deadline
+// is extended by CompileTimeout, so a slow compile never eats into the
+// run timeout. The compile runs under the profile's CPU time limit too:
+// a compiler killed by it (SIGXCPU at the soft limit, exit 152, or
+// SIGKILL at the hard limit, exit 137, which may also be the memory
+// limit) is reported as "compile_timeout" as well, not "compile_error".
+//
+// A failed compile exits with ExitCodeCompileError and reports
+// "compile_error" through a stage report (see stage_reports.go), which
+// ExecuteCode trusts rather than the exit code: a program exiting with
+// ExitCodeCompileError itself is reported as "failed". The compiler's
+// output follows the report, on stderr.
+//
+// Compiler output for template-heavy code can run to hundreds of lines,
+// with the root cause usually first. Only the first
+// MAX_COMPILE_ERROR_LINES (default 50, 0 keeps all) lines of a failed
+// compile are kept, followed by a note of how many were omitted.
+// ============================================
+
+var maxCompileErrorLines = getEnvInt("MAX_COMPILE_ERROR_LINES", 50)
+
+// compileTimeoutEnv carries the compile timeout (whole seconds, 0 = none) into the container
+const compileTimeoutEnv = "RCE_COMPILE_TIMEOUT"
+
+// compileLog holds the compiler's output until the compile's outcome is reported
+const compileLog = "/tmp/.rce-compile.log"
+
+// compileAndRun returns an entrypoint template that runs compile (with
+// the source file as "$1") and then execs binary if it succeeded.
+// timeout exits with 124 (GNU) or the 143 of its SIGTERM (busybox);
+// RLIMIT_CPU kills the compiler with 152 (SIGXCPU) or 137 (SIGKILL).
+func compileAndRun(compile, binary string) []string {
+	script := readStageNonce(stageCompile) + fmt.Sprintf(
+		`if [ "${%[1]s:-0}" -gt 0 ]; then timeout "$%[1]s" %[2]s; else %[2]s; fi >%[3]s 2>&1; rc=$?; `+
+			`case $rc in 124|143|152|137) %[4]s; cat %[3]s >&2; exit %[5]d;; esac; `+
+			`if [ $rc -ne 0 ]; then %[6]s; cat %[3]s >&2; exit %[7]d; fi; `+
+			`cat %[3]s >&2; exec %[8]s`,
+		compileTimeoutEnv, compile, compileLog,
+		reportStage("compile_timeout"), ExitCodeCompileTimeout,
+		reportStage("compile_error"), ExitCodeCompileError, binary)
+	return []string{"sh", "-c", script, "sh", scriptPlaceholder}
+}
+
+// compileTimeoutError describes a compile_timeout from the compiler's exit code
+func compileTimeoutError(langConfig LanguageConfig, profile ResourceProfile, exitCode string) string {
+	switch exitCode {
+	case "152":
+		return fmt.Sprintf("compilation exceeded the %ds CPU time limit", profile.CPUTimeLimitSec)
+	case "137":
+		return fmt.Sprintf("compilation was killed by the %ds CPU time or memory limit", profile.CPUTimeLimitSec)
+	}
+	return fmt.Sprintf("compilation exceeded %v limit", langConfig.CompileTimeout)
+}
+
+// compiles reports whether the job's command runs the language's compile step
+func compiles(langConfig LanguageConfig, entry entryPoint) bool {
+	return langConfig.Compiled && !entry.Stdin && !entry.Eval && !entry.Module && len(langConfig.EntrypointTemplate) > 0
+}
+
+// loadCompileTimeouts applies COMPILE_TIMEOUT_<LANG> overrides to Compiled languages
+func loadCompileTimeouts() error {
+	for name, langConfig := range languageMap {
+		envKey := "COMPILE_TIMEOUT_" + strings.ToUpper(name)
+		if getEnv(envKey, "") != "" {
+			if !langConfig.Compiled {
+				return fmt.Errorf("%s: %s has no compile step", envKey, name)
+			}
+			langConfig.CompileTimeout = getEnvDuration(envKey, langConfig.CompileTimeout)
+			if langConfig.CompileTimeout < 0 {
+				return fmt.Errorf("%s must not be negative", envKey)
+			}
+			languageMap[name] = langConfig
+		}
+		if langConfig.CompileTimeout > 0 {
+			log.Printf("🔨 [%s] Compile timeout: %v", name, langConfig.CompileTimeout)
+		}
+	}
+	return nil
+}
+
+// wholeSeconds rounds a timeout up to the whole seconds timeout(1) takes
+func wholeSeconds(timeout time.Duration) int {
+	return int(math.Ceil(timeout.Seconds()))
+}
+
+// trimCompileErrors keeps the first maxCompileErrorLines lines of compiler output
+func trimCompileErrors(jobID, output string) string {
+	if maxCompileErrorLines <= 0 {
+		return output
+	}
+
+	lines := strings.Split(output, "\n")
+	if len(lines) <= maxCompileErrorLines {
+		return output
+	}
+
+	omitted := len(lines) - maxCompileErrorLines
+	log.Printf("✂️  [%s] Keeping the first %d compile error lines (%d omitted)", jobID, maxCompileErrorLines, omitted)
+	return strings.Join(lines[:maxCompileErrorLines], "\n") + fmt.Sprintf("\n[%d more compile error lines omitted]", omitted)
+}
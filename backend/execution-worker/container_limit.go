@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// ============================================
+// Host Container Limit
+// ============================================
+// A last-resort backstop against runaway container creation: before
+// creating an execution container, the provider counts this
+// deployment's containers on the host (by the CONTAINER_PREFIX label,
+// any state) and refuses to exceed MAX_TOTAL_CONTAINERS. The job is then
+// requeued like any other provider failure.
+//
+// The count is cached for CONTAINER_COUNT_TTL (default 2s) so the daemon
+// isn't listed on every job; containers created in the meantime are
+// added to the cached count. If listing fails the check is skipped.
+//
+// MAX_TOTAL_CONTAINERS=0 (default) disables the limit.
+// ============================================
+
+// ErrContainerLimit means the host already has MAX_TOTAL_CONTAINERS execution containers
+var ErrContainerLimit = errors.New("execution container limit reached on host")
+
+var (
+	maxTotalContainers = getEnvInt("MAX_TOTAL_CONTAINERS", 0)
+	containerCountTTL  = getEnvDuration("CONTAINER_COUNT_TTL", 2*time.Second)
+)
+
+// containerCounter caches the number of execution containers on the host
+type containerCounter struct {
+	mu        sync.Mutex
+	count     int
+	countedAt time.Time
+}
+
+// reserveContainer counts a container about to be created against
+// MAX_TOTAL_CONTAINERS
+func (dp *DockerProvider) reserveContainer(ctx context.Context, jobID string) error {
+	if maxTotalContainers <= 0 {
+		return nil
+	}
+
+	counter := &dp.containers
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+
+	if time.Since(counter.countedAt) > containerCountTTL {
+		list, err := dp.client.ContainerList(ctx, container.ListOptions{
+			All:     true,
+			Filters: filters.NewArgs(filters.Arg("label", labelPrefix+"="+dp.containerPrefix)),
+		})
+		if err != nil {
+			log.Printf("⚠️  [%s] Failed to count containers, skipping limit check: %v", jobID, err)
+			return nil
+		}
+		counter.count = len(list)
+		counter.countedAt = time.Now()
+	}
+
+	if counter.count >= maxTotalContainers {
+		log.Printf("🚨 [%s] %d execution containers on host (limit %d), refusing to create another", jobID, counter.count, maxTotalContainers)
+		return ErrContainerLimit
+	}
+
+	counter.count++
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ============================================
+// Graceful Container Stop
+// ============================================
+// On shutdown the in-flight job used to be aborted by cancelling its
+// context, and its container force-removed mid-write. Instead, the
+// first shutdown step stops taking new jobs and sends every running
+// execution container a stop (SIGTERM, then SIGKILL after
+// CONTAINER_STOP_TIMEOUT, default 2s), so programs can flush their
+// output. Each job then reads its container's final output for the
+// log and is requeued, like any job interrupted by shutdown.
+//
+// CONTAINER_STOP_TIMEOUT plus the time to read output must fit inside
+// SHUTDOWN_TIMEOUT, or the worker loop step gives up and the job is
+// aborted the old way. CONTAINER_STOP_TIMEOUT=0 kills immediately.
+//
+// Containers are tracked from the moment they are created. One created
+// after stopping begins is removed at once, and one stopped before it
+// started is removed once its start returns; both jobs report
+// ErrWorkerStopping.
+// ============================================
+
+// ErrWorkerStopping means the execution was stopped because the worker is shutting down
+var ErrWorkerStopping = errors.New("execution stopped: worker shutting down")
+
+var containerStopTimeout = getEnvDuration("CONTAINER_STOP_TIMEOUT", 2*time.Second)
+
+// runningContainers tracks the execution containers that have been created
+type runningContainers struct {
+	mu       sync.Mutex
+	ids      map[string]string // Container ID -> job ID
+	stopping atomic.Bool       // Set (under mu) once shutdown has stopped them
+}
+
+// track records a container as soon as it is created, so StopRunning
+// can't miss one. Once stopping has begun it refuses with
+// ErrWorkerStopping, and the caller must remove the container.
+func (r *runningContainers) track(containerID, jobID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopping.Load() {
+		return ErrWorkerStopping
+	}
+	if r.ids == nil {
+		r.ids = make(map[string]string)
+	}
+	r.ids[containerID] = jobID
+	return nil
+}
+
+// untrack forgets a container once it is being removed
+func (r *runningContainers) untrack(containerID string) {
+	r.mu.Lock()
+	delete(r.ids, containerID)
+	r.mu.Unlock()
+}
+
+// StopRunning gracefully stops every running execution container. Jobs
+// whose container it stops report ErrWorkerStopping.
+func (dp *DockerProvider) StopRunning(ctx context.Context) error {
+	dp.running.mu.Lock()
+	dp.running.stopping.Store(true)
+	ids := make(map[string]string, len(dp.running.ids))
+	for containerID, jobID := range dp.running.ids {
+		ids[containerID] = jobID
+	}
+	dp.running.mu.Unlock()
+
+	seconds := wholeSeconds(containerStopTimeout)
+	var wg sync.WaitGroup
+	for containerID, jobID := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Printf("🛑 [%s] Stopping container %s (grace %ds)", jobID, containerID[:12], seconds)
+			if err := dp.client.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &seconds}); err != nil {
+				log.Printf("⚠️  [%s] Failed to stop container: %v", jobID, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// logFinalOutput reads a stopped container's output so it isn't lost from the logs
+func (dp *DockerProvider) logFinalOutput(containerID, jobID string, attached *attachedOutput) {
+	captured, err := dp.readOutput(containerID, jobID, attached)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to read final output after stop: %v", jobID, err)
+		return
+	}
+	log.Printf("🛑 [%s] Stopped for shutdown after writing %d bytes of output; requeueing", jobID, captured.Truncation.OriginalBytes)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ============================================
+// CPU Pinning
+// ============================================
+// For benchmarking-style exercises, timings are more reproducible when
+// execution containers always run on the same cores. CPUSET_CPUS (e.g.
+// "2-3" or "4,6") pins containers to those cores, and CPUSET_MODE picks
+// how jobs share them:
+//
+//   shared (default) - every container may use all of CPUSET_CPUS
+//   round-robin      - each job gets one core of the set, the next job
+//                      the next core, so consecutive jobs don't contend
+//
+// The cores are checked against the Docker host's CPU count at startup.
+// Round-robin rotates per worker: workers sharing a host should each get
+// their own CPUSET_CPUS so their jobs don't land on the same core.
+//
+// To verify pinning, run `.\run-tests.ps1 cpuset`: the program prints
+// the cores it may run on (os.sched_getaffinity), which should match
+// CPUSET_CPUS (or one core of it in round-robin mode). The container's
+// HostConfig.CpusetCpus shows the same in `docker inspect` for a failed
+// container kept by KEEP_FAILED_CONTAINERS.
+//
+// Unset (default) leaves scheduling to the host.
+// ============================================
+
+var cpusetMode = getEnv("CPUSET_MODE", "shared")
+
+// cpuPinning assigns cpusets to execution containers
+type cpuPinning struct {
+	cores []int         // Sorted cores from CPUSET_CPUS; empty when pinning is off
+	next  atomic.Uint64 // Next core index in round-robin mode
+}
+
+// parseCpuset parses a cpuset list like "0-2,5" into sorted unique cores
+func parseCpuset(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			last = first
+		}
+		lo, errLo := strconv.Atoi(first)
+		hi, errHi := strconv.Atoi(last)
+		if errLo != nil || errHi != nil || lo < 0 || hi < lo {
+			return nil, fmt.Errorf("invalid CPUSET_CPUS entry %q (expected N or N-M)", part)
+		}
+		for core := lo; core <= hi; core++ {
+			seen[core] = true
+		}
+	}
+
+	cores := make([]int, 0, len(seen))
+	for core := range seen {
+		cores = append(cores, core)
+	}
+	sort.Ints(cores)
+	return cores, nil
+}
+
+// loadCPUPinning reads CPUSET_CPUS and CPUSET_MODE and checks the
+// cores exist on the Docker host
+func (dp *DockerProvider) loadCPUPinning(ctx context.Context) error {
+	spec := getEnv("CPUSET_CPUS", "")
+	if spec == "" {
+		return nil
+	}
+	if cpusetMode != "shared" && cpusetMode != "round-robin" {
+		return fmt.Errorf("CPUSET_MODE must be shared or round-robin, got %q", cpusetMode)
+	}
+
+	cores, err := parseCpuset(spec)
+	if err != nil {
+		return err
+	}
+	info, err := dp.client.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read Docker host CPU count: %w", err)
+	}
+	if highest := cores[len(cores)-1]; highest >= info.NCPU {
+		return fmt.Errorf("CPUSET_CPUS %q includes core %d, but the Docker host has %d CPUs (0-%d)", spec, highest, info.NCPU, info.NCPU-1)
+	}
+
+	dp.cpus.cores = cores
+	log.Printf("📌 Pinning execution containers to CPUs %s (%s)", spec, cpusetMode)
+	return nil
+}
+
+// cpusetFor returns the CpusetCpus for the next container ("" when pinning is off)
+func (p *cpuPinning) cpusetFor() string {
+	if len(p.cores) == 0 {
+		return ""
+	}
+	if cpusetMode == "round-robin" {
+		index := (p.next.Add(1) - 1) % uint64(len(p.cores))
+		return strconv.Itoa(p.cores[index])
+	}
+
+	cores := make([]string, len(p.cores))
+	for i, core := range p.cores {
+		cores[i] = strconv.Itoa(core)
+	}
+	return strings.Join(cores, ",")
+}
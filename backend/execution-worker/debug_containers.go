@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// ============================================
+// Keeping Failed Containers
+// ============================================
+// Execution containers are removed right after each job, so a failure
+// can't be inspected afterwards. With KEEP_FAILED_CONTAINERS=true
+// (default false, meant for development), a container whose program
+// exited non-zero (including timeouts and OOM kills) is left in place
+// for `docker logs` / `docker inspect`.
+//
+// Containers created in this mode carry the labelKeptForDebug label. A
+// sweeper removes kept ones once they've been stopped for longer than
+// DEBUG_CONTAINER_TTL (default 1h), checking every minute, so they
+// don't pile up. Kept containers still count towards
+// MAX_TOTAL_CONTAINERS.
+//
+// Has no effect with AUTO_REMOVE_CONTAINERS, where the daemon removes
+// containers as soon as they exit.
+// ============================================
+
+var (
+	keepFailedContainers = getEnvBool("KEEP_FAILED_CONTAINERS", false)
+	debugContainerTTL    = getEnvDuration("DEBUG_CONTAINER_TTL", time.Hour)
+)
+
+const (
+	labelKeptForDebug  = "rce.keep-on-failure"
+	debugSweepInterval = time.Minute
+)
+
+// keepForDebug reports whether a finished container failed and should be kept
+func (dp *DockerProvider) keepForDebug(ctx context.Context, containerID string) bool {
+	if !keepFailedContainers || dp.autoRemove {
+		return false
+	}
+	info, err := dp.client.ContainerInspect(ctx, containerID)
+	if err != nil || info.State == nil {
+		return false
+	}
+	// A container that never ran has nothing to debug
+	return info.State.Status == "exited" && (info.State.ExitCode != 0 || info.State.OOMKilled)
+}
+
+// startDebugSweeper starts removing expired kept containers. The returned
+// channel is closed once the sweeper has stopped (after ctx is cancelled).
+func startDebugSweeper(ctx context.Context, dp *DockerProvider) <-chan struct{} {
+	done := make(chan struct{})
+	if dp == nil || !keepFailedContainers {
+		close(done)
+		return done
+	}
+	if dp.autoRemove {
+		log.Println("⚠️  KEEP_FAILED_CONTAINERS has no effect with AUTO_REMOVE_CONTAINERS")
+		close(done)
+		return done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(debugSweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				dp.sweepDebugContainers(ctx)
+			}
+		}
+	}()
+
+	log.Printf("🔬 Keeping failed containers for %v", debugContainerTTL)
+	return done
+}
+
+// sweepDebugContainers removes kept containers stopped for longer than debugContainerTTL
+func (dp *DockerProvider) sweepDebugContainers(ctx context.Context) {
+	list, err := dp.client.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", labelPrefix+"="+dp.containerPrefix),
+			filters.Arg("label", labelKeptForDebug),
+			filters.Arg("status", "exited"),
+		),
+	})
+	if err != nil {
+		log.Printf("⚠️  Failed to list kept containers: %v", err)
+		return
+	}
+
+	for _, summary := range list {
+		info, err := dp.client.ContainerInspect(ctx, summary.ID)
+		if err != nil || info.State == nil {
+			continue
+		}
+		finishedAt, err := time.Parse(time.RFC3339Nano, info.State.FinishedAt)
+		if err != nil || time.Since(finishedAt) < debugContainerTTL {
+			continue
+		}
+		dp.removeContainer(ctx, summary.ID, summary.Labels[labelJobID])
+	}
+}
@@ -1,21 +1,27 @@
 package main
 
 import (
-	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 // ============================================
@@ -36,74 +42,303 @@ import (
 
 // LanguageConfig defines execution parameters for each language
 type LanguageConfig struct {
-	Image     string // Docker image to use
-	Extension string // File extension for code files
-	Executor  string // Command/binary to execute the code
-	Timeout   time.Duration
+	Image       string   // Docker image to use
+	ImageDigest string   // Optional pinned digest ("sha256:..."), see image_pinning.go
+	Version     string   // Runtime version shown to users
+	Extension   string   // File extension for code files
+	Executor    string   // Command/binary to execute the code
+	Executors   []string // Candidate executors, first found on PATH wins (overrides Executor, see submission_files.go)
+	ModuleFlag  string   // Optional flag to run a package as the entrypoint (e.g. python -m)
+	Compiled    bool     // Whether the language has a compile step (see compile.go)
+	Timeout     time.Duration
+
+	// CompileTimeout bounds a Compiled language's compile step, separately from Timeout
+	CompileTimeout time.Duration
+
+	// EntrypointTemplate optionally replaces "<Executor> <file>" with a
+	// custom command; "{{SCRIPT}}" is replaced by the file path
+	EntrypointTemplate []string
+
+	// SelfTestCode prints selfTestOutput; run at startup with RUN_SELFTEST (see selftest.go)
+	SelfTestCode string
+
+	// DiagnosticCode probes what the sandbox allows; run at startup with
+	// RUN_SANDBOX_DIAGNOSTIC (see sandbox_diagnostic.go)
+	DiagnosticCode string
+
+	// HarnessTemplate wraps the user's code for jobs with UseHarness (see harness.go)
+	HarnessTemplate string
+
+	// MemorySwapRatio, when set, replaces the resource profile's ratio
+	// of MemorySwap to Memory (see memory_swap.go)
+	MemorySwapRatio float64
+
+	// Preprocessor checks submissions before they run, set by
+	// PREPROCESS_<LANG> (see preprocessors.go)
+	Preprocessor *Preprocessor
+
+	// OutputFilters are patterns for noisy lines removed from the
+	// program's output (see output_filters.go)
+	OutputFilters []string
+
+	// StdinArgs make the Executor read the program from stdin, for
+	// CODE_DELIVERY=stdin (see code_delivery.go); empty if it can't
+	StdinArgs []string
+
+	// EvalArgs are the Executor arguments for jobs with Mode "eval";
+	// "{{SCRIPT}}" is replaced by the file path (see eval_mode.go)
+	EvalArgs []string
+
+	// ExtraMounts are read-only host directories mounted into every
+	// execution container for the language (see tool_mounts.go)
+	ExtraMounts []MountSpec
+
+	// PackageCache is a volume of pre-installed packages, mounted
+	// read-only at PackageCachePath with PackageCacheEnv set (see
+	// package_cache.go). Only languages with a PackageCachePath support one.
+	PackageCache     string
+	PackageCachePath string
+	PackageCacheEnv  []string
+
+	// Env is added to the container environment. It should disable stdout
+	// buffering where the runtime supports it, so output printed before a
+	// timeout or kill isn't lost in an unflushed buffer.
+	Env []string
+
+	// Warm sandbox (optional): when both are set, WarmCmd is run once in
+	// BaseImage at startup and the result is committed as Image.
+	// See warm_images.go.
+	BaseImage string
+	WarmCmd   []string
 }
 
 // ExecutionResult contains the output from code execution
 type ExecutionResult struct {
-	Output        string        // Combined stdout/stderr
-	ExitCode      int           // Container exit code
-	ExecutionTime time.Duration // How long execution took
-	Status        string        // "completed", "failed", "timeout"
-	Error         string        // Error message if any
+	Output           string        // Combined stdout/stderr
+	RawOutput        string        // Output before line-ending normalization or output filters, if either changed it
+	OutputEncoding   string        // "utf8" or "base64", set by processJob (see output_encoding.go)
+	Stdout           []byte        // The program's stdout bytes as written, encoded as Output in base64 mode (not persisted as-is)
+	Stderr           string        // The program's stderr, stored apart from Output in base64 mode
+	Truncation       Truncation    // Whether and why output was cut off by an output limit
+	Artifacts        []Artifact    // Files the program wrote to /output (not persisted as-is)
+	ArtifactNames    []string      // Names of the artifacts stored in GridFS
+	ExitCode         int           // Container exit code
+	ExecutionTime    time.Duration // How long execution took
+	Status           string        // "completed", "failed", "timeout", "cpu_limit_exceeded", "compile_error", "compile_timeout", "lint_failed", "idle_timeout", "setup_failed", "configuration_error", "infrastructure_error", "output_limit_exceeded", "stopped"
+	Error            string        // Error message if any
+	Signal           string        // Signal that terminated the program (e.g. "SIGSEGV"), if any
+	ColdStart        bool          // The image had to be pulled for this execution
+	CPUTimeMs        int64         // CPU time used (user + system), 0 if unavailable
+	OutputBytesTotal int64         // Bytes the program wrote to stdout and stderr, including any past the output limit
+	StderrOnly       bool          // Output went only to stderr (a soft error signal; never changes Status)
+	Variant          string        // A/B variant the job ran under (see variants.go), set by processJob
+	ExecutionID      string        // This run of the job, distinct from its jobId (see execution_id.go), set by processJob
+	ReturnValue      string        // Value of the code's last expression, for jobs with Mode "eval" (see eval_mode.go)
+	ImageDigest      string        // Image that ran the code: repo@sha256:... when known, else the image ID
+	PhaseTimings     phaseTimings  // Time (ms) in each execution phase (see phase_timings.go)
+	Limits           *Limits       // Effective resource limits the container ran with (nil if none was created)
+	Lint             *LintResult   // Outcome of the language's preprocessor check, if it has one (see preprocessors.go)
+	StdinConsumed    *bool         // All of stdin was delivered before the program closed it (nil without stdin)
+	Cached           bool          // Served from the result cache without running a container
+	CachedFrom       string        // For cached results, the run whose result was served (see result_cache.go)
+	QueueTime        time.Duration // Time between submission and pickup (-1 if unknown), set by processJob
+	WorkerVersion    string        // Worker build that stored the result, set by updateJobStatus (see build_info.go)
+	ConfigHash       string        // Hash of the worker's effective configuration, set by updateJobStatus
+	SchemaVersion    int           // Shape of the stored result (see result_schema.go), set by processJob
 }
 
-// Resource limits for security
+// Resource limits for security (defaults for the "small" resource profile)
 const (
-	MemoryLimit         int64 = 128 * 1024 * 1024 // 128 MB
-	MemorySwap          int64 = 128 * 1024 * 1024 // No swap (same as memory)
-	CPUQuota            int64 = 50000             // 50% of one CPU (100000 = 1 CPU)
-	CPUPeriod           int64 = 100000            // Standard CPU period
-	DefaultTimeout            = 5 * time.Second   // Max execution time
-	ExecutionVolume           = "/tmp/executions" // Path inside worker container
-	ExecutionVolumeName       = "rce-executions"  // Docker named volume
+	MemoryLimit            int64 = 128 * 1024 * 1024 // 128 MB
+	CPUQuota               int64 = 50000             // 50% of one CPU (100000 = 1 CPU)
+	CPUPeriod              int64 = 100000            // Standard CPU period
+	CPUTimeLimitSec        int64 = 2                 // Max CPU time (RLIMIT_CPU), independent of wall-clock
+	DefaultTimeout               = 5 * time.Second   // Max execution time
+	ExecutionVolume              = "/tmp/executions" // Path inside worker container
+	ExecutionVolumeName          = "rce-executions"  // Docker named volume
+	ExitCodeSIGXCPU        int64 = 128 + 24          // Process killed by SIGXCPU (RLIMIT_CPU exceeded)
+	ExitCodeCompileError   int64 = 120               // Compile step failed (Compiled languages, see compile.go)
+	ExitCodeCompileTimeout int64 = 121               // Compile step exceeded CompileTimeout
+	ExitCodeSetupFailed    int64 = 122               // Job's SetupScript failed (see setup_teardown.go)
 )
 
 // languageMap maps supported languages to their configurations
 var languageMap = map[string]LanguageConfig{
 	"python": {
-		Image:     "python:3.9-alpine",
-		Extension: ".py",
-		Executor:  "python3",
-		Timeout:   DefaultTimeout,
+		Image:      "python:3.9-alpine",
+		Version:    "3.9",
+		Extension:  ".py",
+		Executor:   "python3",
+		Executors:  []string{"python3", "python"}, // Some images only ship one of them
+		ModuleFlag: "-m",
+		Timeout:    DefaultTimeout,
+		Env:        []string{"PYTHONDONTWRITEBYTECODE=1", "PYTHONUNBUFFERED=1"},
+
+		PackageCachePath: "/opt/rce-packages",
+		PackageCacheEnv:  []string{"PYTHONPATH=/opt/rce-packages"},
+
+		SelfTestCode:   `print("` + selfTestOutput + `")`,
+		DiagnosticCode: pythonSandboxProbe,
+		EvalArgs:       []string{"-c", pythonEvalWrapper, scriptPlaceholder},
+		StdinArgs:      []string{"-"},
+		HarnessTemplate: harnessPlaceholder + `
+
+
+if __name__ == "__main__":
+    import sys
+    print(solution(sys.stdin.read()))
+`,
 	},
 	"javascript": {
 		Image:     "node:18-alpine",
+		Version:   "18",
 		Extension: ".js",
 		Executor:  "node",
 		Timeout:   DefaultTimeout,
+		Env:       []string{"NODE_ENV=production"}, // Node writes pipes unbuffered already
+		OutputFilters: []string{
+			`^\(node:\d+\) ExperimentalWarning: `,
+			"^\\(Use `node --trace-warnings \\.\\.\\.` to show where the warning was created\\)$",
+		},
+
+		PackageCachePath: "/opt/rce-packages",
+		PackageCacheEnv:  []string{"NODE_PATH=/opt/rce-packages/node_modules"},
+
+		SelfTestCode:   `console.log("` + selfTestOutput + `")`,
+		DiagnosticCode: javascriptSandboxProbe,
+		EvalArgs:       []string{"-e", javascriptEvalWrapper, scriptPlaceholder},
+		StdinArgs:      []string{"-"},
+		HarnessTemplate: harnessPlaceholder + `
+
+console.log(solution(require("fs").readFileSync(0, "utf8")));
+`,
+	},
+	// Shell scripts, for systems courses. Unlike the other languages a
+	// script's whole job is to run other binaries, so everything in the
+	// image is reachable: keep the image minimal (the official bash
+	// image is Alpine with busybox utilities, no compilers or network
+	// tools). Containment rests on the same hardening as every language:
+	// no network, all capabilities dropped, nobody, no-new-privileges
+	// and the pids limit.
+	"bash": {
+		Image:     "bash:5.2",
+		Version:   "5.2",
+		Extension: ".sh",
+		Executor:  "bash",
+		Timeout:   DefaultTimeout,
+
+		StdinArgs:      []string{"-s"},
+		SelfTestCode:   `echo "` + selfTestOutput + `"`,
+		DiagnosticCode: bashSandboxProbe,
+	},
+	// Single-file programs compiled with rustc; there is no cargo (and no
+	// network), so only the standard library is available
+	"rust": {
+		Image:     "rust:1.82-alpine",
+		Version:   "1.82",
+		Extension: ".rs",
+		Executor:  "rustc",
+		Compiled:  true,
+		Timeout:   DefaultTimeout,
+
+		CompileTimeout: 10 * time.Second,
+		// println! is line-buffered, so there's no buffering to disable
+
+		EntrypointTemplate: compileAndRun(`rustc --edition 2021 -o /tmp/main "$1"`, "/tmp/main"),
+		SelfTestCode:       `fn main() { println!("` + selfTestOutput + `"); }`,
+		DiagnosticCode:     rustSandboxProbe,
 	},
 }
 
-// DockerProvider handles container-based code execution
-type DockerProvider struct {
-	client *client.Client
+// languageAliases maps alternative names clients send to languageMap keys
+var languageAliases = map[string]string{
+	"py":      "python",
+	"python3": "python",
+	"js":      "javascript",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"rs":      "rust",
 }
 
-// NewDockerProvider creates a new Docker provider instance
-func NewDockerProvider() (*DockerProvider, error) {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+// canonicalLanguage resolves an alias (case-insensitively) to its
+// languageMap key; other names are returned unchanged
+func canonicalLanguage(language string) string {
+	name := strings.ToLower(strings.TrimSpace(language))
+	if canonical, ok := languageAliases[name]; ok {
+		return canonical
+	}
+	if _, ok := languageMap[name]; ok {
+		return name
 	}
+	return language
+}
+
+// DockerProvider handles container-based code execution
+type DockerProvider struct {
+	client  *client.Client
+	runtime string // OCI runtime for execution containers ("" = daemon default, "runsc" = gVisor)
 
-	// Verify connection
+	// platform pins the image and container platform (PLATFORM, nil =
+	// daemon default), see platform.go
+	platform *ocispec.Platform
+
+	// autoRemove lets the daemon delete containers as soon as they exit
+	// (AUTO_REMOVE_CONTAINERS). Nothing leaks if the worker crashes mid-job,
+	// but the container can't be inspected after exit, so output is streamed
+	// via attach from before start instead of read from the logs afterwards,
+	// and OOM kills are reported as a plain SIGKILL.
+	autoRemove bool
+
+	// containerPrefix starts every execution container's name and is
+	// recorded in its labels (CONTAINER_PREFIX), so deployments sharing a
+	// daemon can tell their containers apart
+	containerPrefix string
+
+	mu            sync.Mutex
+	missingImages map[string]time.Time // Images whose pull failed with not-found, see ensureImage
+
+	containers containerCounter  // Execution containers on the host, see container_limit.go
+	pulls      pullAdmission     // Coordinates first pulls with other workers, see pull_admission.go
+	cpus       cpuPinning        // CPUSET_CPUS assignment, see cpuset.go
+	running    runningContainers // Started execution containers, see container_stop.go
+}
+
+// NewDockerProvider creates a new Docker provider instance using the given
+// OCI runtime for execution containers (empty for the daemon default)
+func NewDockerProvider(runtime string) (*DockerProvider, error) {
+	// Connect and verify the daemon accepts the client's API version
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err = cli.Ping(ctx)
+	cli, err := connectDocker(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	platform, err := parsePlatform(getEnv("PLATFORM", ""))
 	if err != nil {
 		cli.Close()
-		return nil, fmt.Errorf("failed to connect to Docker daemon: %w", err)
+		return nil, err
 	}
 
-	return &DockerProvider{client: cli}, nil
+	return &DockerProvider{
+		client:     cli,
+		runtime:    runtime,
+		platform:   platform,
+		autoRemove: getEnvBool("AUTO_REMOVE_CONTAINERS", false),
+
+		containerPrefix: getEnv("CONTAINER_PREFIX", "rce-exec-"),
+
+		missingImages: make(map[string]time.Time),
+	}, nil
+}
+
+// Name identifies the provider in logs
+func (dp *DockerProvider) Name() string {
+	if dp.runtime != "" {
+		return "docker (runtime: " + dp.runtime + ")"
+	}
+	return "docker"
 }
 
 // Close releases Docker client resources
@@ -114,9 +349,11 @@ func (dp *DockerProvider) Close() error {
 	return nil
 }
 
-// ExecuteCode runs user code in an isolated Docker container
-func (dp *DockerProvider) ExecuteCode(ctx context.Context, language, code, jobID string) (*ExecutionResult, error) {
+// ExecuteCode runs a job's code in an isolated Docker container
+func (dp *DockerProvider) ExecuteCode(ctx context.Context, job Job) (*ExecutionResult, error) {
 	startTime := time.Now()
+	job.Language = canonicalLanguage(job.Language)
+	jobID, language := job.JobID, job.Language
 
 	// 1. Validate language
 	langConfig, ok := languageMap[language]
@@ -130,76 +367,273 @@ func (dp *DockerProvider) ExecuteCode(ctx context.Context, language, code, jobID
 		}, nil
 	}
 
-	log.Printf("🐳 [%s] Executing %s code with image: %s", jobID, language, langConfig.Image)
+	// A/B variant overrides (see variants.go)
+	variant, err := resolveVariant(job.Variant)
+	if err != nil {
+		return &ExecutionResult{
+			Output:        "",
+			ExitCode:      1,
+			ExecutionTime: time.Since(startTime),
+			Status:        "failed",
+			Error:         err.Error(),
+		}, nil
+	}
+	job, langConfig = variant.apply(job, langConfig)
 
-	// 2. Create execution context with timeout
-	execCtx, cancel := context.WithTimeout(ctx, langConfig.Timeout)
-	defer cancel()
+	profile, err := resolveProfile(job.Profile)
+	if err != nil {
+		return &ExecutionResult{
+			Output:        "",
+			ExitCode:      1,
+			ExecutionTime: time.Since(startTime),
+			Status:        "failed",
+			Error:         err.Error(),
+		}, nil
+	}
 
-	// 3. Ensure the Docker image exists (pull if needed)
-	if err := dp.ensureImage(execCtx, langConfig.Image); err != nil {
+	if err := validateMode(job, langConfig); err != nil {
 		return &ExecutionResult{
 			Output:        "",
 			ExitCode:      1,
 			ExecutionTime: time.Since(startTime),
 			Status:        "failed",
-			Error:         fmt.Sprintf("failed to pull image: %v", err),
+			Error:         err.Error(),
 		}, nil
 	}
 
-	// 4. Create temporary directory for code within the shared volume
-	// This path is inside the worker container, backed by the named volume
-	execDir := filepath.Join(ExecutionVolume, jobID)
-	if err := os.MkdirAll(execDir, 0755); err != nil {
+	if err := validateArtifactPatterns(job.ArtifactPaths); err != nil {
 		return &ExecutionResult{
 			Output:        "",
 			ExitCode:      1,
 			ExecutionTime: time.Since(startTime),
 			Status:        "failed",
-			Error:         fmt.Sprintf("failed to create execution directory: %v", err),
+			Error:         err.Error(),
 		}, nil
 	}
-	defer func() {
-		// Cleanup: remove the execution directory after completion
-		if err := os.RemoveAll(execDir); err != nil {
-			log.Printf("⚠️  [%s] Failed to cleanup execution directory: %v", jobID, err)
-		}
-	}()
 
-	// 5. Write code to file
-	codeFileName := "script" + langConfig.Extension
-	codeFile := filepath.Join(execDir, codeFileName)
-	if err := os.WriteFile(codeFile, []byte(code), 0644); err != nil {
+	imageRef := imageReference(langConfig)
+	log.Printf("🐳 [%s] Executing %s code with image: %s (profile: %s, variant: %s)", jobID, language, imageRef, profile.Name, variant.Name)
+
+	// 2. Ensure the Docker image exists (pull if needed), with its own
+	// timeout: a first pull can take far longer than any execution
+	pullCtx, pullCancel := context.WithTimeout(ctx, pullTimeout)
+	phases := phaseTimings{}
+	imageStart := time.Now()
+	coldStart, err := dp.ensureImage(pullCtx, imageRef)
+	pullCancel()
+	phases.record("image", imageStart)
+	if err != nil {
+		if errors.Is(err, ErrImageNotFound) {
+			// Permanent misconfiguration, not a flaky network
+			log.Printf("🚨 [%s] Language image %s does not exist: %v", jobID, imageRef, err)
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "configuration_error",
+				Error:         fmt.Sprintf("language image %s is not available", imageRef),
+			}, nil
+		}
+		if errors.Is(err, ErrPlatformUnavailable) {
+			log.Printf("🚨 [%s] %v", jobID, err)
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "configuration_error",
+				Error:         fmt.Sprintf("language image %s is not available for %s", imageRef, platformString(dp.platform)),
+			}, nil
+		}
+		if errors.Is(err, ErrPullWaitExceeded) {
+			log.Printf("⏳ [%s] %v, requeueing", jobID, err)
+			return nil, err
+		}
+		if errors.Is(err, ErrRegistryAuth) {
+			log.Printf("🚨 [%s] %v", jobID, err)
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "configuration_error",
+				Error:         fmt.Sprintf("registry %s rejected the configured credentials for %s", registryHost(imageRef), imageRef),
+			}, nil
+		}
 		return &ExecutionResult{
 			Output:        "",
 			ExitCode:      1,
 			ExecutionTime: time.Since(startTime),
 			Status:        "failed",
-			Error:         fmt.Sprintf("failed to write code file: %v", err),
+			Error:         fmt.Sprintf("failed to pull image: %v", err),
 		}, nil
 	}
 
-	log.Printf("📝 [%s] Code written to: %s", jobID, codeFile)
+	// Wait for room in the host memory budget (see memory_budget.go)
+	releaseBudget, err := dp.awaitMemoryBudget(ctx, jobID, profile.MemoryBytes)
+	if err != nil {
+		if errors.Is(err, ErrMemoryBudget) {
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "configuration_error",
+				Error:         err.Error(),
+			}, nil
+		}
+		return nil, err
+	}
+	defer releaseBudget() // Runs after the containers' removal, deferred below
+
+	// 3. Create execution context with timeout, never running past the caller's deadline
+	timeout := profile.timeoutFor(langConfig)
+	if langConfig.Compiled {
+		timeout += langConfig.CompileTimeout // The run gets its full timeout after compiling
+	}
+	timeout += setupTeardownTimeout(job)
+	if lintEnabled(langConfig) {
+		timeout += lintTimeout // The check runs before the program (see preprocessors.go)
+	}
+	if deadline, ok := job.deadline(); ok {
+		if remaining := time.Until(deadline); remaining < timeout {
+			timeout = max(remaining, 0)
+			log.Printf("⌛ [%s] Timeout shortened to %v by job deadline", jobID, timeout)
+		}
+	}
+	execCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// SECURITY: Fail closed if a pinned image doesn't match its digest
+	if langConfig.ImageDigest != "" {
+		if err := dp.verifyImageDigest(execCtx, imageRef, langConfig.ImageDigest); err != nil {
+			log.Printf("🚨 [%s] %v", jobID, err)
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "failed",
+				Error:         fmt.Sprintf("image verification failed: %v", err),
+			}, nil
+		}
+	}
+	imageDigest := dp.resolveImageDigest(execCtx, jobID, imageRef)
+
+	// 4. Create temporary directory for code within the shared volume,
+	// unless the code is piped to the interpreter (see code_delivery.go)
+	viaStdin := useStdinDelivery(job, langConfig)
+	programStdin := job.Stdin // What the container's stdin carries
+	var execDir string
+	var entry entryPoint
+	var mounts []mount.Mount
+	var lint *LintResult
+	if viaStdin {
+		entry = entryPoint{Name: stdinEntryName, Stdin: true}
+		programStdin = job.Code
+		log.Printf("📝 [%s] Code piped via stdin (CODE_DELIVERY=stdin)", jobID)
+	} else {
+		// This path is inside the worker container, backed by the named volume
+		execDir = filepath.Join(ExecutionVolume, jobID)
+		if err := os.MkdirAll(execDir, 0755); err != nil {
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "failed",
+				Error:         fmt.Sprintf("failed to create execution directory: %v", err),
+			}, nil
+		}
+		defer func() {
+			// Cleanup: remove the execution directory after completion
+			if err := os.RemoveAll(execDir); err != nil {
+				log.Printf("⚠️  [%s] Failed to cleanup execution directory: %v", jobID, err)
+			}
+		}()
+
+		// 5. Write code file(s)
+		entry, err = writeSubmissionFiles(execDir, job, langConfig)
+		if err != nil {
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "failed",
+				Error:         fmt.Sprintf("failed to write code file: %v", err),
+			}, nil
+		}
+
+		log.Printf("📝 [%s] Code written to: %s (entrypoint: %s)", jobID, execDir, entry.Name)
+
+		// Check the code before running it, if the language has a preprocessor
+		if lintEnabled(langConfig) {
+			lintStart := time.Now()
+			lint = dp.runPreprocessor(ctx, job, langConfig, entry, profile)
+			phases.record("lint", lintStart)
+			if !lint.Passed && lint.Error == "" && lintGate == "block" {
+				lint.Blocked = true
+				return &ExecutionResult{
+					Output:        "",
+					ExitCode:      1,
+					ExecutionTime: time.Since(startTime),
+					Status:        "lint_failed",
+					Error:         "code failed the lint check",
+					PhaseTimings:  phases,
+					Lint:          lint,
+				}, nil
+			}
+		}
+
+		// Mount the shared volume
+		// Both worker and sibling containers access the same named volume
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   ExecutionVolumeName, // Named Docker volume
+			Target:   "/code",             // Where it appears in the container
+			ReadOnly: true,                // Code is read-only inside execution container
+		})
+	}
+	mounts = append(mounts, extraMounts(langConfig)...)
+	mounts = append(mounts, packageCacheMounts(langConfig)...)
+
+	// Jobs that want artifacts get a writable /output
+	var artifactDir string
+	if len(job.ArtifactPaths) > 0 {
+		artifactDir, err = prepareArtifactDir(execDir)
+		if err != nil {
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "failed",
+				Error:         fmt.Sprintf("failed to create output directory: %v", err),
+			}, nil
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:          mount.TypeVolume,
+			Source:        ExecutionVolumeName,
+			Target:        artifactMountPath,
+			VolumeOptions: &mount.VolumeOptions{Subpath: jobID + "/" + artifactDirName},
+		})
+	}
 
 	// 6. Build the command to execute
 	// The sibling container mounts the volume at /code, so the script is at /code/<jobId>/script.py
-	scriptPath := fmt.Sprintf("/code/%s/%s", jobID, codeFileName)
-	executeCmd := []string{langConfig.Executor, scriptPath}
+	workDir := fmt.Sprintf("/code/%s", jobID)
+	if viaStdin {
+		workDir = "/tmp" // Nothing is mounted at /code
+	}
+	executeCmd := wrapSetupTeardown(job, buildExecuteCmd(langConfig, workDir, entry))
 
 	// 7. Create container with strict security constraints
 	containerConfig := &container.Config{
-		Image:           langConfig.Image,
+		Image:           imageRef,
 		Cmd:             executeCmd,
-		WorkingDir:      "/code",
-		NetworkDisabled: true,      // SECURITY: No network access
-		User:            "nobody",  // SECURITY: Run as non-root
-		Env: []string{
-			"HOME=/tmp",
-			"PYTHONDONTWRITEBYTECODE=1",
-			"NODE_ENV=production",
-		},
-		// Don't attach stdin
-		AttachStdin:  false,
+		WorkingDir:      workDir,  // Lets multi-file programs import their siblings
+		NetworkDisabled: true,     // SECURITY: No network access
+		User:            "nobody", // SECURITY: Run as non-root
+		Env:             containerEnv(job, langConfig),
+		Labels:          dp.containerLabels(job, profile.MemoryBytes),
+		// Stdin is only opened when the job has input (or its code is piped in)
+		OpenStdin:    programStdin != "",
+		StdinOnce:    programStdin != "",
+		AttachStdin:  programStdin != "",
 		AttachStdout: true,
 		AttachStderr: true,
 		Tty:          false,
@@ -208,42 +642,38 @@ func (dp *DockerProvider) ExecuteCode(ctx context.Context, language, code, jobID
 	hostConfig := &container.HostConfig{
 		// SECURITY: Resource limits
 		Resources: container.Resources{
-			Memory:     MemoryLimit,  // 128MB max memory
-			MemorySwap: MemorySwap,   // No swap
-			CPUQuota:   CPUQuota,     // 0.5 CPU cores
+			Memory:     profile.MemoryBytes,               // 128MB max memory by default
+			MemorySwap: profile.memorySwapFor(langConfig), // No swap (same as memory) unless a swap ratio is set
+			CPUQuota:   profile.CPUQuota,                  // 0.5 CPU cores by default
 			CPUPeriod:  CPUPeriod,
-			PidsLimit:  int64Ptr(50), // Limit number of processes
+			CpusetCpus: dp.cpus.cpusetFor(),         // "" unless CPUSET_CPUS pins cores
+			PidsLimit:  int64Ptr(profile.PidsLimit), // Limit number of processes
+			Ulimits:    cpuTimeUlimits(profile),     // CPU-time limit (RLIMIT_CPU)
 		},
 		// SECURITY: Additional restrictions
-		ReadonlyRootfs: false,                        // Some languages need /tmp writes
-		AutoRemove:     false,                        // We'll remove manually after getting logs
+		ReadonlyRootfs: false,         // Some languages need /tmp writes
+		AutoRemove:     dp.autoRemove, // Off by default: we remove manually after getting logs
 		SecurityOpt:    []string{"no-new-privileges"},
-		CapDrop:        []string{"ALL"},             // Drop all capabilities
-		
-		// Mount the shared volume
-		// Both worker and sibling containers access the same named volume
-		Mounts: []mount.Mount{
-			{
-				Type:     mount.TypeVolume,
-				Source:   ExecutionVolumeName, // Named Docker volume
-				Target:   "/code",              // Where it appears in the container
-				ReadOnly: true,                 // Code is read-only inside execution container
-			},
-		},
+		CapDrop:        []string{"ALL"}, // Drop all capabilities
+		Runtime:        dp.runtime,      // e.g. "runsc" for gVisor
+		Mounts:         mounts,
+		// The kernel ignores default-handled signals sent to a namespace's
+		// PID 1, so the program must not be PID 1: without an init, the
+		// RLIMIT_CPU soft limit's SIGXCPU would be dropped and only the
+		// hard limit's SIGKILL would land
+		Init: boolPtr(true),
 	}
 
-	containerName := fmt.Sprintf("rce-exec-%s", jobID)
+	limits := appliedLimits(profile.Name, hostConfig.Resources, timeout)
+	containerName := dp.containerPrefix + jobID
 
-	// 8. Create the container
+	// 8. Create the container (unless the host already has too many)
 	log.Printf("🏗️  [%s] Creating container: %s", jobID, containerName)
-	resp, err := dp.client.ContainerCreate(
-		execCtx,
-		containerConfig,
-		hostConfig,
-		nil, // NetworkingConfig
-		nil, // Platform
-		containerName,
-	)
+	createStart := time.Now()
+	containerID, err := dp.createContainer(execCtx, jobID, containerName, containerConfig, hostConfig)
+	if errors.Is(err, ErrContainerLimit) || errors.Is(err, ErrWorkerStopping) {
+		return nil, err
+	}
 	if err != nil {
 		return &ExecutionResult{
 			Output:        "",
@@ -254,19 +684,90 @@ func (dp *DockerProvider) ExecuteCode(ctx context.Context, language, code, jobID
 		}, nil
 	}
 
-	containerID := resp.ID
+	phases.record("create", createStart)
 	log.Printf("📦 [%s] Container created: %s", jobID, containerID[:12])
 
-	// Ensure cleanup happens even if we panic
+	// Ensure cleanup happens even if we panic. With AutoRemove this is the
+	// fallback for containers that never started (and so are never reaped).
 	defer func() {
 		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cleanupCancel()
+		dp.running.untrack(containerID)
+		if dp.keepForDebug(cleanupCtx, containerID) {
+			log.Printf("🔬 [%s] Keeping failed container %s for debugging (KEEP_FAILED_CONTAINERS)", jobID, containerID[:12])
+			return
+		}
 		dp.removeContainer(cleanupCtx, containerID, jobID)
 	}()
 
+	// Wrappers around the program report their stages out of band (see
+	// stage_reports.go), and find the job's scripts in files
+	var stages []string
+	if compiles(langConfig, entry) {
+		stages = append(stages, stageCompile)
+	}
+	if hasSetupOrTeardown(job) {
+		stages = append(stages, stageSetup)
+	}
+	var nonce string
+	if len(stages) > 0 {
+		nonce = newStageNonce()
+		files := setupTeardownFiles(job)
+		for _, stage := range stages {
+			files[stageNonceFile(stage)] = nonce
+		}
+		if err := dp.copyControlFiles(execCtx, containerID, files); err != nil {
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "failed",
+				Error:         fmt.Sprintf("failed to prepare container: %v", err),
+			}, nil
+		}
+	}
+
+	// With AutoRemove the container vanishes on exit, StopOnOutput,
+	// KILL_ON_OUTPUT_LIMIT and IDLE_TIMEOUT need output as it's produced,
+	// and stdin is sent over the attach connection, so attach and register
+	// the wait before starting it
+	var attached *attachedOutput
+	var firstLine <-chan struct{}   // Stays nil (never ready) unless StopOnOutput
+	var outputLimit <-chan struct{} // Stays nil unless KILL_ON_OUTPUT_LIMIT
+	var idle <-chan struct{}        // Stays nil unless IDLE_TIMEOUT
+	waitCondition := container.WaitConditionNextExit
+	if dp.autoRemove {
+		waitCondition = container.WaitConditionRemoved
+	}
+	if dp.autoRemove || job.StopOnOutput || programStdin != "" || killOnOutputLimit || idleTimeout > 0 {
+		capture := newOutputCapture()
+		if job.StopOnOutput {
+			firstLine = capture.watchFirstLine()
+		}
+		if killOnOutputLimit {
+			outputLimit = capture.watchLimit()
+		}
+		attached, err = dp.attachOutput(execCtx, containerID, capture, programStdin != "")
+		if err != nil {
+			return &ExecutionResult{
+				Output:        "",
+				ExitCode:      1,
+				ExecutionTime: time.Since(startTime),
+				Status:        "failed",
+				Error:         fmt.Sprintf("failed to attach to container: %v", err),
+			}, nil
+		}
+		defer attached.Close()
+	}
+	statusCh, errCh := dp.client.ContainerWait(execCtx, containerID, waitCondition)
+
 	// 9. Start the container
 	log.Printf("▶️  [%s] Starting container...", jobID)
+	launchStart := time.Now()
 	if err := dp.client.ContainerStart(execCtx, containerID, container.StartOptions{}); err != nil {
+		if isMountError(err) {
+			return dp.mountErrorResult(jobID, err, startTime), nil
+		}
 		return &ExecutionResult{
 			Output:        "",
 			ExitCode:      1,
@@ -275,14 +776,32 @@ func (dp *DockerProvider) ExecuteCode(ctx context.Context, language, code, jobID
 			Error:         fmt.Sprintf("failed to start container: %v", err),
 		}, nil
 	}
+	phases.record("start", launchStart)
+	if dp.running.stopping.Load() {
+		// StopRunning may have stopped it before it started; the
+		// deferred removal kills it
+		return nil, ErrWorkerStopping
+	}
+	waitStart := time.Now()
+
+	if programStdin != "" {
+		go attached.writeStdin(jobID, programStdin)
+	}
+	if idleTimeout > 0 {
+		idle = attached.capture.watchIdle(execCtx, idleTimeout)
+	}
+
+	usage := dp.sampleUsage(containerID)
+	defer usage.cancel() // Timed-out executions never call Stop
 
 	// 10. Wait for container to finish (with timeout)
-	log.Printf("⏳ [%s] Waiting for execution (timeout: %v)...", jobID, langConfig.Timeout)
-	statusCh, errCh := dp.client.ContainerWait(execCtx, containerID, container.WaitConditionNotRunning)
+	log.Printf("⏳ [%s] Waiting for execution (timeout: %v)...", jobID, timeout)
 
 	var exitCode int64
+	var exited bool // The program exited by itself; its status is derived below
 	var execStatus string
 	var execError string
+	var signal string
 
 	select {
 	case err := <-errCh:
@@ -290,18 +809,13 @@ func (dp *DockerProvider) ExecuteCode(ctx context.Context, language, code, jobID
 			// Check if it's a timeout
 			if execCtx.Err() == context.DeadlineExceeded {
 				log.Printf("⏰ [%s] TIMEOUT - Killing container", jobID)
-				// Force kill the container
-				killCtx, killCancel := context.WithTimeout(context.Background(), 5*time.Second)
-				defer killCancel()
-				dp.client.ContainerKill(killCtx, containerID, "SIGKILL")
-
-				return &ExecutionResult{
-					Output:        "Execution timed out. Your code took too long to execute.",
-					ExitCode:      124, // Standard timeout exit code
-					ExecutionTime: time.Since(startTime),
-					Status:        "timeout",
-					Error:         fmt.Sprintf("execution exceeded %v limit", langConfig.Timeout),
-				}, nil
+				phases.record("wait", waitStart)
+				result := dp.timeoutResult(containerID, jobID, attached, startTime, timeout)
+				result.ImageDigest = imageDigest
+				result.PhaseTimings = phases
+				result.Limits = limits
+				result.Lint = lint
+				return result, nil
 			}
 			execStatus = "failed"
 			execError = fmt.Sprintf("container wait error: %v", err)
@@ -309,81 +823,443 @@ func (dp *DockerProvider) ExecuteCode(ctx context.Context, language, code, jobID
 		}
 	case status := <-statusCh:
 		exitCode = status.StatusCode
-		if exitCode == 0 {
-			execStatus = "completed"
-		} else {
-			execStatus = "failed"
-			if status.Error != nil {
-				execError = status.Error.Message
-			}
+		exited = true
+		if status.Error != nil {
+			execError = status.Error.Message
 		}
-	case <-execCtx.Done():
-		log.Printf("⏰ [%s] Context deadline exceeded - Killing container", jobID)
+	case <-firstLine:
+		log.Printf("✂️  [%s] First output line received - Killing container (stopOnOutput)", jobID)
 		killCtx, killCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer killCancel()
 		dp.client.ContainerKill(killCtx, containerID, "SIGKILL")
-
-		return &ExecutionResult{
-			Output:        "Execution timed out. Your code took too long to execute.",
-			ExitCode:      124,
-			ExecutionTime: time.Since(startTime),
-			Status:        "timeout",
-			Error:         fmt.Sprintf("execution exceeded %v limit", langConfig.Timeout),
-		}, nil
+		killCancel()
+		execStatus = "stopped"
+		exitCode = 128 + 9 // SIGKILL
+	case <-outputLimit:
+		log.Printf("✂️  [%s] Output limit reached - Killing container", jobID)
+		killCtx, killCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		dp.client.ContainerKill(killCtx, containerID, "SIGKILL")
+		killCancel()
+		execStatus = "output_limit_exceeded"
+		execError = "program output exceeded the limit"
+		exitCode = 128 + 9 // SIGKILL
+	case <-idle:
+		log.Printf("💤 [%s] No output for %v - Killing container", jobID, idleTimeout)
+		killCtx, killCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		dp.client.ContainerKill(killCtx, containerID, "SIGKILL")
+		killCancel()
+		execStatus = "idle_timeout"
+		execError = fmt.Sprintf("no output for %v", idleTimeout)
+		exitCode = 128 + 9 // SIGKILL
+	case <-execCtx.Done():
+		log.Printf("⏰ [%s] Context deadline exceeded - Killing container", jobID)
+		phases.record("wait", waitStart)
+		result := dp.timeoutResult(containerID, jobID, attached, startTime, timeout)
+		result.ImageDigest = imageDigest
+		result.PhaseTimings = phases
+		result.Limits = limits
+		result.Lint = lint
+		return result, nil
 	}
 
+	phases.record("wait", waitStart)
+	if dp.running.stopping.Load() {
+		// Stopped by StopRunning: the exit says nothing about the program
+		dp.logFinalOutput(containerID, jobID, attached)
+		return nil, ErrWorkerStopping
+	}
 	log.Printf("✅ [%s] Container finished with exit code: %d", jobID, exitCode)
+	cpuTime := usage.Stop()
 
 	// 11. Capture logs (stdout + stderr)
-	output, logErr := dp.getContainerLogs(containerID, jobID)
+	captured, logErr := dp.readOutput(containerID, jobID, attached)
 	if logErr != nil {
 		log.Printf("⚠️  [%s] Failed to get logs: %v", jobID, logErr)
 		if execError == "" {
 			execError = fmt.Sprintf("failed to retrieve output: %v", logErr)
 		}
 	}
+	reports := extractStageReports(jobID, nonce, &captured)
+	if exited {
+		execStatus, execError, signal = dp.exitStatus(containerID, langConfig, profile, exitCode, reports, cpuTime, execError)
+	}
+	if report, ok := stageStatus(reports, "teardown_failed"); ok {
+		log.Printf("⚠️  [%s] Teardown script failed with exit code %s", jobID, report.exitCode)
+	}
+	captured.Text = remapHarnessLines(captured.Text, entry)
+	filterOutput(jobID, language, &captured)
+	var returnValue string
+	if entry.Eval {
+		captured.Text, returnValue = extractReturnValue(jobID, captured.Text)
+	}
+	if captured.StderrOnly && exitCode == 0 {
+		log.Printf("⚠️  [%s] Exited 0 but wrote only to stderr", jobID)
+	}
+	if execStatus == "compile_error" {
+		captured.Text = trimCompileErrors(jobID, captured.Text)
+	}
+
+	var stdinConsumed *bool
+	if job.Stdin != "" {
+		consumed := attached.stdinConsumed()
+		stdinConsumed = &consumed
+	}
 
 	executionTime := time.Since(startTime)
 	log.Printf("⏱️  [%s] Total execution time: %v", jobID, executionTime)
 
+	// 12. Read back artifacts before the execution directory is removed
+	var artifacts []Artifact
+	if artifactDir != "" {
+		artifacts = collectArtifacts(artifactDir, job.ArtifactPaths, jobID)
+		log.Printf("📎 [%s] Collected %d artifact(s)", jobID, len(artifacts))
+	}
+
 	return &ExecutionResult{
-		Output:        output,
-		ExitCode:      int(exitCode),
-		ExecutionTime: executionTime,
-		Status:        execStatus,
-		Error:         execError,
+		Output:           captured.Text,
+		RawOutput:        captured.Raw,
+		Stdout:           captured.Stdout,
+		Stderr:           captured.Stderr,
+		Truncation:       captured.Truncation,
+		OutputBytesTotal: captured.Truncation.OriginalBytes,
+		StderrOnly:       captured.StderrOnly,
+		ReturnValue:      returnValue,
+		ExitCode:         int(exitCode),
+		ExecutionTime:    executionTime,
+		Status:           execStatus,
+		Error:            execError,
+		Signal:           signal,
+		Artifacts:        artifacts,
+		ColdStart:        coldStart,
+		CPUTimeMs:        cpuTime.Milliseconds(),
+		StdinConsumed:    stdinConsumed,
+		ImageDigest:      imageDigest,
+		PhaseTimings:     phases,
+		Limits:           limits,
+		Lint:             lint,
 	}, nil
 }
 
-// ensureImage pulls the Docker image if it doesn't exist locally
-func (dp *DockerProvider) ensureImage(ctx context.Context, imageName string) error {
-	// Check if image exists locally
-	_, _, err := dp.client.ImageInspectWithRaw(ctx, imageName)
+// containerEnv builds the environment for an execution container.
+//
+// Seeded jobs get RCE_SEED so submissions can seed their own RNG
+// (random.seed(int(os.environ["RCE_SEED"])) in Python, a seeded PRNG in
+// JavaScript, whose Math.random can't be seeded). Python also gets
+// PYTHONHASHSEED so set/dict iteration order is reproducible.
+//
+// The language's Env comes first (see LanguageConfig.Env), then its
+// PackageCacheEnv when it has a package cache.
+//
+// HOME is the container's own /tmp. Every job gets a fresh container that
+// is removed afterwards, so no files survive from one job to the next.
+func containerEnv(job Job, langConfig LanguageConfig) []string {
+	env := append([]string{"HOME=/tmp"}, langConfig.Env...)
+	if langConfig.PackageCache != "" {
+		env = append(env, langConfig.PackageCacheEnv...)
+	}
+	env = append(env, setupTeardownEnv(job)...)
+	if langConfig.Compiled && langConfig.CompileTimeout > 0 {
+		env = append(env, fmt.Sprintf("%s=%d", compileTimeoutEnv, wholeSeconds(langConfig.CompileTimeout)))
+	}
+
+	if job.Seed != nil {
+		env = append(env,
+			fmt.Sprintf("RCE_SEED=%d", *job.Seed),
+			// PYTHONHASHSEED must be in [0, 4294967295]
+			fmt.Sprintf("PYTHONHASHSEED=%d", uint32(*job.Seed)),
+		)
+	}
+
+	return env
+}
+
+// createContainer creates a sandbox container for a job, counted against
+// MAX_TOTAL_CONTAINERS and tracked for StopRunning from the moment it
+// exists. It returns ErrContainerLimit or ErrWorkerStopping as is; the
+// caller must untrack and remove the container when done.
+func (dp *DockerProvider) createContainer(ctx context.Context, jobID, name string, config *container.Config, hostConfig *container.HostConfig) (string, error) {
+	if err := dp.reserveContainer(ctx, jobID); err != nil {
+		return "", err
+	}
+
+	resp, err := dp.client.ContainerCreate(ctx, config, hostConfig, nil, dp.platform, name)
+	if err != nil {
+		logAPIVersionMismatch(err)
+		return "", err
+	}
+
+	if err := dp.running.track(resp.ID, jobID); err != nil {
+		// Created after StopRunning took its snapshot
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cleanupCancel()
+		dp.removeContainer(cleanupCtx, resp.ID, jobID)
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+// cpuTimeUlimits is the RLIMIT_CPU of a sandbox container: the kernel
+// sends SIGXCPU at the soft limit and SIGKILL at the hard limit, so
+// sleeping programs aren't penalised
+func cpuTimeUlimits(profile ResourceProfile) []*container.Ulimit {
+	return []*container.Ulimit{
+		{Name: "cpu", Soft: profile.CPUTimeLimitSec, Hard: profile.CPUTimeLimitSec + 1},
+	}
+}
+
+// Labels identifying execution containers; cleanup of containers must
+// filter on labelPrefix so it never touches another deployment's
+const (
+	labelPrefix = "rce.prefix" // The deployment's CONTAINER_PREFIX
+	labelJobID  = "rce.job-id"
+)
+
+// containerLabels returns the labels for a job's execution container
+func (dp *DockerProvider) containerLabels(job Job, memoryBytes int64) map[string]string {
+	labels := map[string]string{
+		labelPrefix:      dp.containerPrefix,
+		labelJobID:       job.JobID,
+		labelMemoryBytes: strconv.FormatInt(memoryBytes, 10), // See memory_budget.go
+	}
+	if job.ExecutionID != "" {
+		labels[labelExecutionID] = job.ExecutionID // See execution_id.go
+	}
+	if keepFailedContainers {
+		labels[labelKeptForDebug] = "true" // See debug_containers.go
+	}
+	return labels
+}
+
+// exitStatus derives the status, error and signal of a program that
+// exited by itself, from the stage reports of its wrappers (never from
+// exit codes the program could choose, see stage_reports.go) and
+// otherwise its exit code. waitError is the daemon's wait error, if any.
+func (dp *DockerProvider) exitStatus(containerID string, langConfig LanguageConfig, profile ResourceProfile, exitCode int64, reports []stageReport, cpuTime time.Duration, waitError string) (status, errMsg, signal string) {
+	if _, ok := stageStatus(reports, "setup_failed"); ok {
+		return "setup_failed", fmt.Sprintf("setup script failed or exceeded %v limit", setupTimeout), ""
+	}
+	if _, ok := stageStatus(reports, "compile_error"); ok {
+		return "compile_error", "compilation failed", ""
+	}
+	if report, ok := stageStatus(reports, "compile_timeout"); ok {
+		return "compile_timeout", compileTimeoutError(langConfig, profile, report.exitCode), ""
+	}
+	if exitCode == 0 {
+		return "completed", "", ""
+	}
+
+	signal, description := dp.terminationSignal(containerID, exitCode)
+	if exitCode == ExitCodeSIGXCPU && cpuLimitReached(profile, cpuTime) {
+		// Killed by the kernel for exceeding RLIMIT_CPU (the init reports
+		// its child's signal as 128+24); the CPU time rules out a
+		// program merely exiting with that code
+		return "cpu_limit_exceeded", fmt.Sprintf("execution exceeded %ds CPU time limit", profile.CPUTimeLimitSec), signal
+	}
+	errMsg = waitError
+	if signal != "" && errMsg == "" {
+		errMsg = fmt.Sprintf("program terminated by %s: %s", signal, description)
+	}
+	return "failed", errMsg, signal
+}
+
+// timeoutNotice is appended to whatever a timed-out program printed
+const timeoutNotice = "Execution timed out. Your code took too long to execute."
+
+// timeoutResult kills a timed-out container and returns the output it produced
+// before the deadline, followed by the timeout notice
+func (dp *DockerProvider) timeoutResult(containerID, jobID string, attached *attachedOutput, startTime time.Time, timeout time.Duration) *ExecutionResult {
+	// Force kill the container
+	killCtx, killCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer killCancel()
+	dp.client.ContainerKill(killCtx, containerID, "SIGKILL")
+
+	// The container is stopped but not yet removed, so its logs are still readable
+	output := timeoutNotice
+	partial, err := dp.readOutput(containerID, jobID, attached)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to get partial output after timeout: %v", jobID, err)
+	} else if partial.Text != "" {
+		output = partial.Text + "\n\n" + timeoutNotice
+	}
+
+	return &ExecutionResult{
+		Output:           output,
+		Stdout:           partial.Stdout,
+		Stderr:           partial.Stderr,
+		Truncation:       partial.Truncation,
+		OutputBytesTotal: partial.Truncation.OriginalBytes,
+		StderrOnly:       partial.StderrOnly,
+		ExitCode:         124, // Standard timeout exit code
+		ExecutionTime:    time.Since(startTime),
+		Status:           "timeout",
+		Error:            fmt.Sprintf("execution exceeded %v limit", timeout),
+	}
+}
+
+// terminationSignal returns the signal that killed the program, if any, with a
+// friendly description. Exit codes above 128 are decoded as 128+signum; SIGKILL
+// is attributed to the OOM killer when the container state says so.
+func (dp *DockerProvider) terminationSignal(containerID string, exitCode int64) (string, string) {
+	if exitCode <= 128 {
+		return "", ""
+	}
+
+	signal, ok := signalNames[exitCode-128]
+	if !ok {
+		return fmt.Sprintf("SIG%d", exitCode-128), "terminated by signal"
+	}
+
+	if signal == "SIGKILL" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if info, err := dp.client.ContainerInspect(ctx, containerID); err == nil && info.State != nil && info.State.OOMKilled {
+			return signal, "killed for exceeding the memory limit"
+		}
+	}
+
+	return signal, signalDescriptions[signal]
+}
+
+// signalNames maps common Linux signal numbers to their names
+var signalNames = map[int64]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+	24: "SIGXCPU",
+	25: "SIGXFSZ",
+}
+
+// signalDescriptions gives user-facing explanations for common signals
+var signalDescriptions = map[string]string{
+	"SIGHUP":  "hangup",
+	"SIGINT":  "interrupted",
+	"SIGQUIT": "quit",
+	"SIGILL":  "illegal instruction",
+	"SIGTRAP": "trace/breakpoint trap",
+	"SIGABRT": "aborted, e.g. a failed assertion",
+	"SIGBUS":  "bus error, invalid memory access",
+	"SIGFPE":  "arithmetic error, e.g. integer division by zero",
+	"SIGKILL": "killed",
+	"SIGSEGV": "segmentation fault, invalid memory access",
+	"SIGPIPE": "wrote to a closed pipe",
+	"SIGALRM": "alarm clock",
+	"SIGTERM": "terminated",
+	"SIGXCPU": "exceeded the CPU time limit",
+	"SIGXFSZ": "exceeded the file size limit",
+}
+
+// ErrImageNotFound marks a pull that failed because the image or tag doesn't exist
+var ErrImageNotFound = errors.New("image not found in registry")
+
+// missingImageTTL is how long a not-found image is remembered. Jobs for it
+// fail immediately instead of re-pulling, until an operator fixes the config.
+const missingImageTTL = 5 * time.Minute
+
+// ensureImage pulls the Docker image if it doesn't exist locally, and
+// reports whether a pull was performed
+func (dp *DockerProvider) ensureImage(ctx context.Context, imageName string) (bool, error) {
+	// Check if image exists locally, for the right platform
+	info, _, err := dp.client.ImageInspectWithRaw(ctx, imageName)
 	if err == nil {
-		// Image exists locally
-		return nil
+		if matchesPlatform(dp.platform, info.Os, info.Architecture, info.Variant) {
+			return false, nil
+		}
+		log.Printf("📥 Local image %s is %s/%s, pulling %s", imageName, info.Os, info.Architecture, platformString(dp.platform))
+	}
+
+	// Don't retry a pull that is known to fail permanently
+	dp.mu.Lock()
+	missingSince, known := dp.missingImages[imageName]
+	dp.mu.Unlock()
+	if known && time.Since(missingSince) < missingImageTTL {
+		return false, fmt.Errorf("%w: %s", ErrImageNotFound, imageName)
 	}
 
+	// Let one worker per host pull while the rest wait (see pull_admission.go)
+	release, ready, err := dp.admitPull(ctx, imageName)
+	if err != nil {
+		return false, err
+	}
+	if ready {
+		return true, nil
+	}
+	defer release()
+
 	log.Printf("📥 Pulling image: %s", imageName)
 
-	reader, err := dp.client.ImagePull(ctx, imageName, image.PullOptions{})
+	registryAuth, err := registryAuthFor(imageName)
 	if err != nil {
-		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+		return false, fmt.Errorf("failed to encode registry credentials: %w", err)
+	}
+
+	reader, err := dp.client.ImagePull(ctx, imageName, image.PullOptions{
+		RegistryAuth: registryAuth,
+		Platform:     platformString(dp.platform),
+	})
+	if err != nil {
+		if dp.platform != nil && isPlatformUnavailable(err) {
+			return false, dp.platformUnavailable(imageName, err)
+		}
+		if registryAuth != "" && isRegistryAuthError(err) {
+			return false, fmt.Errorf("%w: %s for %s: %v", ErrRegistryAuth, registryHost(imageName), imageName, err)
+		}
+		if isImageNotFound(err) {
+			dp.mu.Lock()
+			dp.missingImages[imageName] = time.Now()
+			dp.mu.Unlock()
+			return false, fmt.Errorf("%w: %s: %v", ErrImageNotFound, imageName, err)
+		}
+		return false, fmt.Errorf("failed to pull image %s: %w", imageName, err)
 	}
 	defer reader.Close()
 
 	// Consume the pull output (required to complete the pull)
-	_, err = io.Copy(io.Discard, reader)
-	if err != nil {
-		return fmt.Errorf("error reading pull output: %w", err)
+	if err := followPull(imageName, reader); err != nil {
+		if dp.platform != nil && isPlatformUnavailable(err) {
+			return false, dp.platformUnavailable(imageName, err)
+		}
+		return false, fmt.Errorf("failed to pull image %s: %w", imageName, err)
 	}
 
+	dp.mu.Lock()
+	delete(dp.missingImages, imageName)
+	dp.mu.Unlock()
+
 	log.Printf("✅ Image pulled successfully: %s", imageName)
-	return nil
+	return true, nil
+}
+
+// platformUnavailable remembers an image with no variant for the platform, like a missing image
+func (dp *DockerProvider) platformUnavailable(imageName string, err error) error {
+	dp.mu.Lock()
+	dp.missingImages[imageName] = time.Now()
+	dp.mu.Unlock()
+	return fmt.Errorf("%w: %s for %s: %v", ErrPlatformUnavailable, imageName, platformString(dp.platform), err)
+}
+
+// isImageNotFound reports whether a pull error means the image or tag doesn't
+// exist (as opposed to a transient network or daemon error)
+func isImageNotFound(err error) bool {
+	if client.IsErrNotFound(err) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "manifest unknown") ||
+		strings.Contains(msg, "not found") ||
+		strings.Contains(msg, "repository does not exist")
 }
 
 // getContainerLogs retrieves stdout and stderr from a container
-func (dp *DockerProvider) getContainerLogs(containerID, jobID string) (string, error) {
+func (dp *DockerProvider) getContainerLogs(containerID, jobID string) (capturedOutput, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -396,41 +1272,154 @@ func (dp *DockerProvider) getContainerLogs(containerID, jobID string) (string, e
 
 	logs, err := dp.client.ContainerLogs(ctx, containerID, options)
 	if err != nil {
-		return "", fmt.Errorf("failed to get container logs: %w", err)
+		return capturedOutput{}, fmt.Errorf("failed to get container logs: %w", err)
 	}
 	defer logs.Close()
 
 	// Docker multiplexes stdout and stderr in the log stream
 	// We need to demux them using stdcopy
-	var stdout, stderr bytes.Buffer
-	_, err = stdcopy.StdCopy(&stdout, &stderr, logs)
-	if err != nil {
-		// Fallback: just read everything
-		logs.Close()
-		logs, _ = dp.client.ContainerLogs(ctx, containerID, options)
-		if logs != nil {
-			var buf bytes.Buffer
-			io.Copy(&buf, logs)
-			return buf.String(), nil
+	capture := newOutputCapture()
+	_, err = stdcopy.StdCopy(capture.Stdout(), capture.Stderr(), logs)
+	if err != nil && !errors.Is(err, errOutputLimit) {
+		// Fallback: re-read the raw stream and parse the frames ourselves,
+		// so multiplexing headers never leak into the output
+		log.Printf("⚠️  [%s] Log demux failed, parsing raw stream: %v", jobID, err)
+		raw, rawErr := dp.client.ContainerLogs(ctx, containerID, options)
+		if rawErr != nil {
+			return capturedOutput{}, fmt.Errorf("failed to read container logs: %w", err)
 		}
-		return "", fmt.Errorf("failed to read container logs: %w", err)
+		defer raw.Close()
+
+		data, readErr := io.ReadAll(raw)
+		if readErr != nil && len(data) == 0 {
+			return capturedOutput{}, fmt.Errorf("failed to read container logs: %w", readErr)
+		}
+
+		capture.Reset()
+		demuxLogStream(data, capture.Stdout(), capture.Stderr())
 	}
 
-	// Combine stdout and stderr
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		if output != "" && !strings.HasSuffix(output, "\n") {
-			output += "\n"
+	return capture.Result(), nil
+}
+
+// attachedOutput collects a container's output from an attach stream.
+// Used with AutoRemove, where logs can't be read after the container exits,
+// with StopOnOutput, which reacts to output as it arrives, and for stdin.
+type attachedOutput struct {
+	stream  types.HijackedResponse
+	capture *outputCapture
+	err     error
+	done    chan struct{}
+
+	stdinDone    chan struct{} // Closed once writeStdin returns
+	stdinWritten bool          // All of stdin was written and closed
+}
+
+// attachOutput attaches to a created (not yet started) container's stdout/stderr,
+// and its stdin if requested
+func (dp *DockerProvider) attachOutput(ctx context.Context, containerID string, capture *outputCapture, stdin bool) (*attachedOutput, error) {
+	stream, err := dp.client.ContainerAttach(ctx, containerID, container.AttachOptions{
+		Stream: true,
+		Stdin:  stdin,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attached := &attachedOutput{stream: stream, capture: capture, done: make(chan struct{}), stdinDone: make(chan struct{})}
+	go func() {
+		defer close(attached.done)
+		_, attached.err = stdcopy.StdCopy(attached.capture.Stdout(), attached.capture.Stderr(), stream.Reader)
+	}()
+	return attached, nil
+}
+
+// Close ends the attach stream
+func (a *attachedOutput) Close() {
+	a.stream.Close()
+}
+
+// logFlushDelay is how long to wait after the container exits before
+// reading its logs, which the daemon may still be flushing (LOG_FLUSH_DELAY,
+// default 20ms). Empty logs are read once more after another delay, so a
+// fast-exiting program isn't reported as having printed nothing.
+// An attach stream ends with the container and needs no delay.
+var logFlushDelay = getEnvDuration("LOG_FLUSH_DELAY", 20*time.Millisecond)
+
+// readOutput returns the container's output, from the attach stream if
+// there is one, otherwise from the container logs
+func (dp *DockerProvider) readOutput(containerID, jobID string, attached *attachedOutput) (capturedOutput, error) {
+	if attached == nil {
+		time.Sleep(logFlushDelay)
+		output, err := dp.getContainerLogs(containerID, jobID)
+		if err == nil && output.Text == "" && logFlushDelay > 0 {
+			time.Sleep(logFlushDelay)
+			output, err = dp.getContainerLogs(containerID, jobID)
 		}
-		output += stderr.String()
+		return output, err
 	}
 
-	// Trim trailing whitespace
-	output = strings.TrimRight(output, "\n\r\t ")
+	select {
+	case <-attached.done:
+	case <-time.After(5 * time.Second):
+		// The stream should end with the container; don't hang on it
+		attached.Close()
+		<-attached.done
+	}
 
+	output := attached.capture.Result()
+	if attached.err != nil && !errors.Is(attached.err, errOutputLimit) && output.Text == "" {
+		return capturedOutput{}, fmt.Errorf("failed to read attached output: %w", attached.err)
+	}
 	return output, nil
 }
 
+// Docker log stream frame format (non-TTY containers):
+//
+//	[stream byte][0][0][0][4-byte big-endian payload size][payload...]
+//
+// where stream is 0 (stdin), 1 (stdout) or 2 (stderr).
+const logFrameHeaderSize = 8
+
+// demuxLogStream is a lenient parser for the multiplexed log format, used
+// when stdcopy rejects a stream. Well-formed frames are routed to stdout or
+// stderr. A truncated final frame keeps whatever payload is present. If a
+// header is invalid, the remainder is treated as plain text (as produced
+// by a TTY container).
+func demuxLogStream(data []byte, stdout, stderr io.Writer) {
+	for len(data) > 0 {
+		if !isLogFrameHeader(data) {
+			stdout.Write(data)
+			return
+		}
+
+		size := int(binary.BigEndian.Uint32(data[4:logFrameHeaderSize]))
+		payload := data[logFrameHeaderSize:]
+		if size > len(payload) {
+			size = len(payload)
+		}
+
+		target := stdout
+		if data[0] == 2 {
+			target = stderr
+		}
+		if _, err := target.Write(payload[:size]); err != nil {
+			return // Output limit reached
+		}
+		data = payload[size:]
+	}
+}
+
+// isLogFrameHeader reports whether data starts with a valid frame header
+func isLogFrameHeader(data []byte) bool {
+	if len(data) < logFrameHeaderSize {
+		return false
+	}
+	return data[0] <= 2 && data[1] == 0 && data[2] == 0 && data[3] == 0
+}
+
 // removeContainer forcefully removes a container
 func (dp *DockerProvider) removeContainer(ctx context.Context, containerID, jobID string) {
 	log.Printf("🧹 [%s] Removing container: %s", jobID, containerID[:12])
@@ -439,7 +1428,10 @@ func (dp *DockerProvider) removeContainer(ctx context.Context, containerID, jobI
 		Force:         true, // Force removal even if running
 		RemoveVolumes: true, // Remove associated volumes
 	})
-	if err != nil {
+	if err != nil && client.IsErrNotFound(err) {
+		// Already reaped by the daemon (AutoRemove)
+		log.Printf("✅ [%s] Container already removed", jobID)
+	} else if err != nil {
 		log.Printf("⚠️  [%s] Failed to remove container: %v", jobID, err)
 	} else {
 		log.Printf("✅ [%s] Container removed successfully", jobID)
@@ -455,9 +1447,42 @@ func GetSupportedLanguages() []string {
 	return languages
 }
 
-// IsLanguageSupported checks if a language is supported
+// LanguageInfo describes a supported language and the limits applied to it
+type LanguageInfo struct {
+	Name             string `json:"name"`
+	Image            string `json:"image"`
+	Version          string `json:"version"`
+	Compiled         bool   `json:"compiled"`
+	TimeoutMs        int64  `json:"timeoutMs"`
+	CompileTimeoutMs int64  `json:"compileTimeoutMs,omitempty"`
+	MemoryLimitBytes int64  `json:"memoryLimitBytes"`
+	CPUTimeLimitSec  int64  `json:"cpuTimeLimitSec"`
+}
+
+// DescribeLanguages returns structured info for every supported language, sorted by name.
+// Limits are those of the default resource profile.
+func DescribeLanguages() []LanguageInfo {
+	profile := resourceProfiles[DefaultProfile]
+	infos := make([]LanguageInfo, 0, len(languageMap))
+	for name, langConfig := range languageMap {
+		infos = append(infos, LanguageInfo{
+			Name:             name,
+			Image:            langConfig.Image,
+			Version:          langConfig.Version,
+			Compiled:         langConfig.Compiled,
+			TimeoutMs:        profile.timeoutFor(langConfig).Milliseconds(),
+			CompileTimeoutMs: langConfig.CompileTimeout.Milliseconds(),
+			MemoryLimitBytes: profile.MemoryBytes,
+			CPUTimeLimitSec:  profile.CPUTimeLimitSec,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// IsLanguageSupported checks if a language (or an alias for one) is supported
 func IsLanguageSupported(language string) bool {
-	_, ok := languageMap[language]
+	_, ok := languageMap[canonicalLanguage(language)]
 	return ok
 }
 
@@ -465,3 +1490,8 @@ func IsLanguageSupported(language string) bool {
 func int64Ptr(i int64) *int64 {
 	return &i
 }
+
+// Helper function for bool pointers
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// logFrame builds a multiplexed log frame with the given stream and payload
+func logFrame(stream byte, payload string) []byte {
+	size := len(payload)
+	header := []byte{stream, 0, 0, 0, byte(size >> 24), byte(size >> 16), byte(size >> 8), byte(size)}
+	return append(header, payload...)
+}
+
+func joinFrames(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestIsLogFrameHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"stdin frame", logFrame(0, "x"), true},
+		{"stdout frame", logFrame(1, "x"), true},
+		{"stderr frame", logFrame(2, "x"), true},
+		{"zero-length frame", logFrame(1, ""), true},
+		{"unknown stream", logFrame(3, "x"), false},
+		{"non-zero padding", []byte{1, 0, 1, 0, 0, 0, 0, 1, 'x'}, false},
+		{"plain text", []byte("hello world"), false},
+		{"short header", []byte{1, 0, 0, 0, 0, 0, 0}, false},
+		{"empty", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLogFrameHeader(tt.data); got != tt.want {
+				t.Errorf("isLogFrameHeader(%q) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDemuxLogStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "stdout and stderr frames",
+			data:       joinFrames(logFrame(1, "out\n"), logFrame(2, "err\n"), logFrame(1, "more\n")),
+			wantStdout: "out\nmore\n",
+			wantStderr: "err\n",
+		},
+		{
+			name:       "zero-length frames",
+			data:       joinFrames(logFrame(1, ""), logFrame(2, ""), logFrame(1, "after")),
+			wantStdout: "after",
+		},
+		{
+			name:       "truncated final frame keeps its payload",
+			data:       joinFrames(logFrame(1, "whole"), logFrame(2, "partial payload")[:logFrameHeaderSize+7]),
+			wantStdout: "whole",
+			wantStderr: "partial",
+		},
+		{
+			name:       "truncated header is plain text",
+			data:       joinFrames(logFrame(1, "ok"), []byte{2, 0, 0}),
+			wantStdout: "ok\x02\x00\x00",
+		},
+		{
+			name:       "plain text stream",
+			data:       []byte("tty output\n"),
+			wantStdout: "tty output\n",
+		},
+		{
+			// A program printing bytes that look like a header inside a
+			// frame's payload must not split the frame
+			name:       "fake header inside payload",
+			data:       joinFrames(logFrame(1, string(logFrame(2, "spoof"))), logFrame(2, "real")),
+			wantStdout: string(logFrame(2, "spoof")),
+			wantStderr: "real",
+		},
+		{
+			name:       "invalid header after valid frames",
+			data:       joinFrames(logFrame(2, "err"), []byte("\x05\x00\x00\x00rest")),
+			wantStdout: "\x05\x00\x00\x00rest",
+			wantStderr: "err",
+		},
+		{
+			name: "empty stream",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			demuxLogStream(tt.data, &stdout, &stderr)
+			if got := stdout.String(); got != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", got, tt.wantStdout)
+			}
+			if got := stderr.String(); got != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", got, tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/docker/docker/client"
+)
+
+// ============================================
+// Docker API Version Mismatches
+// ============================================
+// The client negotiates its API version with the daemon, but on some
+// older hosts a call still fails with e.g. "client version 1.47 is too
+// new. Maximum supported API version is 1.41". At startup the worker
+// probes a versioned endpoint and, on a mismatch, logs both versions.
+//
+// DOCKER_API_VERSION (e.g. 1.41) is a fallback pin rather than an
+// override: the client negotiates first and only pins to
+// DOCKER_API_VERSION, and retries, after a mismatch. Without it the
+// worker refuses to start, naming the version to set. A mismatch on a
+// later call is logged with the same diagnostic.
+// ============================================
+
+// apiVersionMismatchPattern matches the daemon's version mismatch errors
+// (capturing client version, too new/old, and the supported bound)
+var apiVersionMismatchPattern = regexp.MustCompile(`client version ([0-9.]+) is too (new|old)\. (?:Maximum|Minimum) supported API version is ([0-9.]+)`)
+
+// apiVersionMismatch describes a daemon rejecting the client's API version
+type apiVersionMismatch struct {
+	clientVersion string
+	tooNew        bool
+	serverVersion string // The daemon's maximum (tooNew) or minimum supported version
+}
+
+// parseAPIVersionMismatch reports whether err is an API version mismatch
+func parseAPIVersionMismatch(err error) (apiVersionMismatch, bool) {
+	if err == nil {
+		return apiVersionMismatch{}, false
+	}
+	match := apiVersionMismatchPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return apiVersionMismatch{}, false
+	}
+	return apiVersionMismatch{clientVersion: match[1], tooNew: match[2] == "new", serverVersion: match[3]}, true
+}
+
+// logAPIVersionMismatch logs an actionable diagnostic if err is an API
+// version mismatch, and reports whether it was
+func logAPIVersionMismatch(err error) bool {
+	mismatch, ok := parseAPIVersionMismatch(err)
+	if !ok {
+		return false
+	}
+	bound := "maximum"
+	if !mismatch.tooNew {
+		bound = "minimum"
+	}
+	log.Printf("🚨 Docker API version mismatch: client uses %s, daemon %s is %s. Set DOCKER_API_VERSION=%s to pin the client.",
+		mismatch.clientVersion, bound, mismatch.serverVersion, mismatch.serverVersion)
+	return true
+}
+
+// connectDocker creates a negotiating Docker client and checks that the
+// daemon accepts its API version, pinning to DOCKER_API_VERSION on a mismatch
+func connectDocker(ctx context.Context) (*client.Client, error) {
+	cli, err := client.NewClientWithOpts(
+		client.WithTLSClientConfigFromEnv(),
+		client.WithHostFromEnv(),
+		client.WithAPIVersionNegotiation(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+
+	if _, err := cli.Ping(ctx); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("failed to connect to Docker daemon: %w", err)
+	}
+
+	// Ping is unversioned; ServerVersion is the first call that can mismatch
+	_, err = cli.ServerVersion(ctx)
+	if err == nil {
+		return cli, nil
+	}
+	cli.Close()
+	if !logAPIVersionMismatch(err) {
+		return nil, fmt.Errorf("failed to query Docker version: %w", err)
+	}
+
+	pinned := getEnv(client.EnvOverrideAPIVersion, "")
+	if pinned == "" {
+		return nil, fmt.Errorf("docker API version mismatch (set %s): %w", client.EnvOverrideAPIVersion, err)
+	}
+
+	log.Printf("📌 Retrying with Docker API version %s (%s)", pinned, client.EnvOverrideAPIVersion)
+	cli, err = client.NewClientWithOpts(
+		client.WithTLSClientConfigFromEnv(),
+		client.WithHostFromEnv(),
+		client.WithVersion(pinned),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	if _, err := cli.ServerVersion(ctx); err != nil {
+		cli.Close()
+		logAPIVersionMismatch(err)
+		return nil, fmt.Errorf("docker API version %s is not supported by the daemon: %w", pinned, err)
+	}
+	return cli, nil
+}
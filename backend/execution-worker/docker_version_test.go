@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseAPIVersionMismatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   apiVersionMismatch
+		wantOK bool
+	}{
+		{
+			name:   "client too new",
+			err:    errors.New("Error response from daemon: client version 1.47 is too new. Maximum supported API version is 1.43"),
+			want:   apiVersionMismatch{clientVersion: "1.47", tooNew: true, serverVersion: "1.43"},
+			wantOK: true,
+		},
+		{
+			name:   "client too old",
+			err:    errors.New("Error response from daemon: client version 1.12 is too old. Minimum supported API version is 1.24, please upgrade your client to a newer version"),
+			want:   apiVersionMismatch{clientVersion: "1.12", tooNew: false, serverVersion: "1.24"},
+			wantOK: true,
+		},
+		{
+			name:   "wrapped error",
+			err:    fmt.Errorf("failed to create container: %w", errors.New("client version 1.45 is too new. Maximum supported API version is 1.41")),
+			want:   apiVersionMismatch{clientVersion: "1.45", tooNew: true, serverVersion: "1.41"},
+			wantOK: true,
+		},
+		{
+			name: "unrelated daemon error",
+			err:  errors.New("Error response from daemon: No such image: python:3.9-alpine"),
+		},
+		{
+			name: "connection refused",
+			err:  errors.New("Cannot connect to the Docker daemon at unix:///var/run/docker.sock. Is the docker daemon running?"),
+		},
+		{
+			name: "nil error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseAPIVersionMismatch(tt.err)
+			if ok != tt.wantOK {
+				t.Fatalf("parseAPIVersionMismatch() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("parseAPIVersionMismatch() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
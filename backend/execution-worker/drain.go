@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// ============================================
+// Drain Mode
+// ============================================
+// For rolling deploys, SIGUSR1 drains the worker instead of stopping it:
+//   1. GET /ready starts returning 503, so the load balancer stops routing
+//   2. No new jobs are pulled from the queue
+//   3. The in-flight job (if any) runs to completion
+//   4. The worker exits as on SIGTERM
+// A SIGTERM/SIGINT received while draining shuts down immediately.
+//
+// Manual verification:
+//   docker compose exec execution-worker wget -qO- localhost:8081/ready   # 200 ready
+//   (submit a long-running job, e.g. test-timeout.json)
+//   docker kill --signal=SIGUSR1 rce-execution-worker
+//   docker compose logs -f execution-worker   # job finishes, then "Drain complete"
+//
+// SIGUSR1 doesn't exist on Windows; there drain mode is unavailable.
+// ============================================
+
+// ready is reported by GET /ready: true once the worker loop is pulling jobs
+var ready atomic.Bool
+
+// handleReady reports whether this worker should be routed new work
+func handleReady(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "unready"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
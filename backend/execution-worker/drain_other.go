@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// drainSignals are the signals that put the worker in drain mode (none here)
+var drainSignals []os.Signal
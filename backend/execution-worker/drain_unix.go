@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// drainSignals are the signals that put the worker in drain mode
+var drainSignals = []os.Signal{syscall.SIGUSR1}
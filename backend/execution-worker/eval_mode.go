@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+)
+
+// ============================================
+// Eval Mode
+// ============================================
+// A job with Mode "eval" runs its code like a notebook cell: the code
+// runs as usual, and if it ends with an expression, that expression's
+// value is returned in ReturnValue alongside the output.
+//
+// The language's EvalArgs replace the usual "<Executor> <file>": a small
+// wrapper that runs the entrypoint file and prints the value as a
+// returnValueMarker line, which is then removed from the output.
+//
+//   - Python evaluates the last statement if it is an expression and
+//     returns its repr() ("None" included).
+//   - JavaScript returns util.inspect() of the script's completion value
+//     (as `node -p` prints), so `let x = 1` gives "undefined".
+//
+// Limitations:
+//   - Only for languages with EvalArgs; not with UseHarness or a package
+//     entrypoint.
+//   - No value is returned if the program fails or exits non-zero.
+//   - JavaScript has no top-level await; a Promise is returned as
+//     "Promise { <pending> }", not awaited. The value is taken when
+//     the process exits, after any timers have run.
+//   - The value counts towards the output limit: if output is
+//     truncated, ReturnValue may be lost.
+//   - Python tracebacks include a frame for the wrapper itself.
+// ============================================
+
+const (
+	modeRun  = "run"
+	modeEval = "eval"
+)
+
+// returnValueMarker prefixes the line carrying the JSON-encoded return value
+const returnValueMarker = "__RCE_RETURN_VALUE__"
+
+// pythonEvalWrapper is run with python -c; argv[1] is the entrypoint file
+var pythonEvalWrapper = fmt.Sprintf(`import ast, json, sys
+path = sys.argv[1]
+sys.argv = sys.argv[1:]
+with open(path) as f:
+    tree = ast.parse(f.read(), path)
+last = tree.body.pop() if tree.body and isinstance(tree.body[-1], ast.Expr) else None
+scope = {"__name__": "__main__", "__file__": path}
+exec(compile(tree, path, "exec"), scope)
+if last is not None:
+    value = eval(compile(ast.Expression(last.value), path, "eval"), scope)
+    print("\n%s " + json.dumps(repr(value)), flush=True)
+`, returnValueMarker)
+
+// javascriptEvalWrapper is run with node -e; argv[1] is the entrypoint file
+var javascriptEvalWrapper = fmt.Sprintf(`const file = process.argv[1];
+const value = require("vm").runInThisContext(require("fs").readFileSync(file, "utf8"), { filename: file });
+process.on("exit", (code) => {
+  if (code === 0) process.stdout.write("\n%s " + JSON.stringify(require("util").inspect(value)) + "\n");
+});
+`, returnValueMarker)
+
+// validateMode checks the job's Mode against the language
+func validateMode(job Job, langConfig LanguageConfig) error {
+	switch job.Mode {
+	case "", modeRun:
+		return nil
+	case modeEval:
+		if len(langConfig.EvalArgs) == 0 {
+			return fmt.Errorf("language %s has no eval mode", job.Language)
+		}
+		if job.UseHarness {
+			return fmt.Errorf("eval mode can't be combined with a harness")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q (want %q or %q)", job.Mode, modeRun, modeEval)
+	}
+}
+
+// buildEvalCmd runs the entrypoint file through the language's eval wrapper
+func buildEvalCmd(langConfig LanguageConfig, workDir string, entry entryPoint) []string {
+	script := path.Join(workDir, entry.Name)
+	args := make([]string, len(langConfig.EvalArgs))
+	for i, arg := range langConfig.EvalArgs {
+		if arg == scriptPlaceholder {
+			arg = script
+		}
+		args[i] = arg
+	}
+	return executorCommand(langConfig, args...)
+}
+
+// extractReturnValue removes the return value line from output and
+// returns both; value is empty if there is none
+func extractReturnValue(jobID, output string) (string, string) {
+	if !strings.Contains(output, returnValueMarker) {
+		return output, ""
+	}
+
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		encoded, found := strings.CutPrefix(lines[i], returnValueMarker+" ")
+		if !found {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal([]byte(strings.TrimRight(encoded, "\r")), &value); err != nil {
+			log.Printf("⚠️  [%s] Malformed return value, left in output: %v", jobID, err)
+			return output, ""
+		}
+		kept := append(lines[:i:i], lines[i+1:]...)
+		return strings.TrimRight(strings.Join(kept, "\n"), "\n\r\t "), value
+	}
+	return output, ""
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================
+// Execution IDs
+// ============================================
+// A job can run more than once: after a transient failure or a
+// mid-execution shutdown it is requeued (see job_retry.go), and each
+// pickup is a fresh run. To tell runs apart, processJob gives every
+// ExecuteCode call its own executionId (a random UUID), distinct from
+// the logical jobId. It appears in:
+//
+//   - the worker's logs for the run
+//   - the container's rce.execution-id label
+//   - the result, as "executionId"
+//   - the submission's "events" array, which gets one entry per run
+//     ({executionId, status, exitCode, executionTimeMs, error, worker,
+//     at}); a run that ended without a result (a provider error or a
+//     shutdown, after which the job is requeued or failed) is recorded
+//     with status "interrupted"
+//
+// A cached result keeps the executionId of the run that produced it,
+// and adds no event since nothing ran.
+//
+// executionId is not a metric label: one series per execution would
+// grow without bound.
+// ============================================
+
+// labelExecutionID records the run a container belongs to
+const labelExecutionID = "rce.execution-id"
+
+// executionEvent is one entry of a submission's events history
+type executionEvent struct {
+	ExecutionID     string `bson:"executionId"`
+	Status          string `bson:"status"`
+	ExitCode        int    `bson:"exitCode"`
+	ExecutionTimeMs int64  `bson:"executionTimeMs"`
+	Error           string `bson:"error,omitempty"`
+	Worker          string `bson:"worker"`
+	At              string `bson:"at"`
+}
+
+// newExecutionID returns a random (version 4) UUID
+func newExecutionID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand doesn't fail on supported platforms; stay unique regardless
+		return fmt.Sprintf("%032x", time.Now().UnixNano())
+	}
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newExecutionEvent summarises a run for the events history
+func newExecutionEvent(executionID, status string, result *ExecutionResult, errMessage string) executionEvent {
+	event := executionEvent{
+		ExecutionID: executionID,
+		Status:      status,
+		Error:       errMessage,
+		Worker:      workerName(),
+		At:          time.Now().UTC().Format(time.RFC3339),
+	}
+	if result != nil {
+		event.ExitCode = result.ExitCode
+		event.ExecutionTimeMs = result.ExecutionTime.Milliseconds()
+		if event.Error == "" {
+			event.Error = result.Error
+		}
+	}
+	return event
+}
+
+// executionEventPush returns the $push appending a run to the events history,
+// or nil if the result didn't come from a run
+func executionEventPush(status string, result *ExecutionResult) bson.M {
+	if result == nil || result.ExecutionID == "" || result.Cached {
+		return nil
+	}
+	return bson.M{"events": newExecutionEvent(result.ExecutionID, status, result, "")}
+}
+
+// recordInterruptedExecution appends a run that ended without a result to the events history
+func recordInterruptedExecution(ctx context.Context, job Job, reason string) {
+	if job.ExecutionID == "" {
+		return
+	}
+	_, err := mongoDb.Collection("submissions").UpdateOne(ctx,
+		bson.M{"jobId": job.JobID},
+		bson.M{"$push": bson.M{"events": newExecutionEvent(job.ExecutionID, "interrupted", nil, reason)}},
+	)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to record execution %s: %v", job.JobID, job.ExecutionID, err)
+	}
+}
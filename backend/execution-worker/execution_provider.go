@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// ============================================
+// Execution Providers
+// ============================================
+// The job pipeline only depends on ExecutionProvider, so stronger
+// isolation backends can be swapped in without touching processJob.
+//
+// Selected with EXECUTION_PROVIDER:
+//   - docker (default): runc containers via the Docker daemon
+//   - gvisor:           Docker with the gVisor "runsc" runtime
+//                       (runsc must be installed and registered with the daemon)
+//
+// DOCKER_RUNTIME overrides the OCI runtime for the docker provider directly.
+// ============================================
+
+// ExecutionProvider runs a job's code in an isolated sandbox
+type ExecutionProvider interface {
+	// Name identifies the provider in logs
+	Name() string
+	// ExecuteCode runs the job and reports its result. Sandbox failures that
+	// are the user's concern are reported in the result; err is reserved for
+	// provider failures.
+	ExecuteCode(ctx context.Context, job Job) (*ExecutionResult, error)
+	// Close releases provider resources
+	Close() error
+}
+
+// NewExecutionProvider creates the provider selected by kind
+func NewExecutionProvider(kind string) (ExecutionProvider, error) {
+	switch kind {
+	case "docker", "":
+		return NewDockerProvider(getEnv("DOCKER_RUNTIME", ""))
+	case "gvisor":
+		return NewDockerProvider("runsc")
+	default:
+		return nil, fmt.Errorf("unknown execution provider %q (expected docker or gvisor)", kind)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================
+// Execution Stats
+// ============================================
+// For usage dashboards, finished jobs are counted per day (UTC),
+// language, variant (see variants.go) and final status in memory, and
+// every STATS_FLUSH_INTERVAL (default 30s, 0 disables) the counts are
+// added to the "stats" collection with an atomic $inc on one document
+// per (day, language, variant, status):
+//
+//   {day: "2024-01-31", language: "python", variant: "control", status: "completed", count: 42}
+//
+// Documents written before variants existed have no variant field.
+// Finished jobs are also counted in rce_executions_total.
+//
+// Counts that fail to flush are kept for the next attempt. The final
+// flush runs during shutdown, after the worker loop has stopped, so no
+// finished job goes uncounted.
+// ============================================
+
+var statsFlushInterval = getEnvDuration("STATS_FLUSH_INTERVAL", 30*time.Second)
+
+var executionsTotal = newCounter("rce_executions_total", "Finished jobs by language, variant and final status")
+
+// statsKey identifies one stats document
+type statsKey struct {
+	day      string
+	language string
+	variant  string
+	status   string
+}
+
+var (
+	statsMu     sync.Mutex
+	statsCounts = make(map[statsKey]int64)
+)
+
+// recordExecution counts a job that finished with the given status
+func recordExecution(job Job, status string) {
+	variant := variantLabel(job)
+	executionsTotal.Inc("language", job.Language, "variant", variant, "status", status)
+	if statsFlushInterval <= 0 {
+		return
+	}
+	key := statsKey{day: time.Now().UTC().Format("2006-01-02"), language: job.Language, variant: variant, status: status}
+
+	statsMu.Lock()
+	statsCounts[key]++
+	statsMu.Unlock()
+}
+
+// statsFlusher periodically writes the counts to MongoDB
+type statsFlusher struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startStatsFlusher starts the periodic flush
+func startStatsFlusher() *statsFlusher {
+	f := &statsFlusher{stop: make(chan struct{}), done: make(chan struct{})}
+	if statsFlushInterval <= 0 {
+		close(f.done)
+		return f
+	}
+
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(statsFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-f.stop:
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				flushStats(ctx)
+				cancel()
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				flushStats(ctx)
+				cancel()
+			}
+		}
+	}()
+	return f
+}
+
+// Stop runs a final flush and waits for it, for shutdown
+func (f *statsFlusher) Stop(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return nil
+	default:
+	}
+	close(f.stop)
+	return waitFor(ctx, f.done)
+}
+
+// flushStats adds the pending counts to the stats collection
+func flushStats(ctx context.Context) {
+	statsMu.Lock()
+	pending := statsCounts
+	statsCounts = make(map[statsKey]int64)
+	statsMu.Unlock()
+
+	collection := mongoDb.Collection("stats")
+	for key, count := range pending {
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"day": key.day, "language": key.language, "variant": key.variant, "status": key.status},
+			bson.M{"$inc": bson.M{"count": count}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			log.Printf("⚠️  Failed to flush execution stats (will retry): %v", err)
+			statsMu.Lock()
+			statsCounts[key] += count
+			statsMu.Unlock()
+		}
+	}
+}
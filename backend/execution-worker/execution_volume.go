@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// ============================================
+// Execution Volume Health
+// ============================================
+// Submissions reach their containers through the rce-executions named
+// volume. It is checked at startup, but it can still disappear later
+// (removed by hand, or lost when the daemon restarts), and then
+// ContainerStart fails with a mount error.
+//
+// Such failures are reported as "infrastructure_error" rather than
+// "failed", so they aren't mistaken for a problem with the user's code.
+// Before the job is failed, the worker checks the volume and recreates
+// it if it's missing, so later jobs can succeed.
+// ============================================
+
+// isMountError reports whether a container start failed mounting its volumes
+func isMountError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "mount") || strings.Contains(msg, "volume")
+}
+
+// mountErrorResult reports a mount failure and tries to restore the volume
+func (dp *DockerProvider) mountErrorResult(jobID string, err error, startTime time.Time) *ExecutionResult {
+	log.Printf("🚨 [%s] Container start failed mounting volume %s: %v", jobID, ExecutionVolumeName, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if restoreErr := dp.ensureExecutionVolume(ctx); restoreErr != nil {
+		log.Printf("🚨 [%s] Execution volume check failed: %v", jobID, restoreErr)
+	}
+
+	return &ExecutionResult{
+		Output:        "",
+		ExitCode:      1,
+		ExecutionTime: time.Since(startTime),
+		Status:        "infrastructure_error",
+		Error:         fmt.Sprintf("sandbox volume %s is unavailable, check that it exists on the Docker host: %v", ExecutionVolumeName, err),
+	}
+}
+
+// ensureExecutionVolume recreates the execution volume if the daemon no longer has it
+func (dp *DockerProvider) ensureExecutionVolume(ctx context.Context) error {
+	_, err := dp.client.VolumeInspect(ctx, ExecutionVolumeName)
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to inspect volume %s: %w", ExecutionVolumeName, err)
+	}
+
+	log.Printf("🔧 Execution volume %s is missing, recreating it", ExecutionVolumeName)
+	if _, err := dp.client.VolumeCreate(ctx, volume.CreateOptions{Name: ExecutionVolumeName}); err != nil {
+		return fmt.Errorf("failed to recreate volume %s: %w", ExecutionVolumeName, err)
+	}
+	return nil
+}
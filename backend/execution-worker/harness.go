@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ============================================
+// Code Harness
+// ============================================
+// With UseHarness, a student submits only a function (e.g. solution)
+// and the language's HarnessTemplate supplies the code that calls it:
+// the entrypoint file becomes the template with {{USER_CODE}} replaced
+// by the submitted code.
+//
+// The built-in templates put {{USER_CODE}} first, so error line numbers
+// already match the user's code. For a template with lines before the
+// placeholder, line references to the entrypoint file in the output
+// (Python 'script.py", line N', Node 'script.js:N') are shifted back by
+// that many lines. References to harness lines after the user's code are
+// left pointing past its end.
+// ============================================
+
+const harnessPlaceholder = "{{USER_CODE}}"
+
+// applyHarness wraps code in the template and returns the result along
+// with the number of template lines before the user's code
+func applyHarness(template, code string) (string, int, error) {
+	index := strings.Index(template, harnessPlaceholder)
+	if index < 0 {
+		return "", 0, fmt.Errorf("harness template has no %s placeholder", harnessPlaceholder)
+	}
+
+	offset := strings.Count(template[:index], "\n")
+	return template[:index] + code + template[index+len(harnessPlaceholder):], offset, nil
+}
+
+// remapHarnessLines shifts line references to the entrypoint file in
+// output so they are relative to the user's code
+func remapHarnessLines(output string, entry entryPoint) string {
+	if entry.LineOffset == 0 {
+		return output
+	}
+
+	pattern := regexp.MustCompile(regexp.QuoteMeta(path.Base(entry.Name)) + `(", line |:)(\d+)`)
+	return pattern.ReplaceAllStringFunc(output, func(ref string) string {
+		parts := pattern.FindStringSubmatch(ref)
+		line, err := strconv.Atoi(parts[2])
+		if err != nil || line <= entry.LineOffset {
+			return ref // Inside the harness preamble
+		}
+		return strings.TrimSuffix(ref, parts[2]) + strconv.Itoa(line-entry.LineOffset)
+	})
+}
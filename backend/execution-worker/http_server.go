@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ============================================
+// HTTP API
+// ============================================
+// A small read-only HTTP server exposed alongside the queue consumer.
+//
+// Endpoints:
+//   GET /languages - Supported languages with their runtime limits
+//   GET /metrics   - Worker metrics (Prometheus text format)
+//   GET /ready     - 200 while accepting jobs, 503 otherwise (see drain.go)
+// ============================================
+
+// startHTTPServer starts the worker's HTTP server in the background
+func startHTTPServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /languages", handleLanguages)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+	mux.HandleFunc("GET /ready", handleReady)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		log.Printf("🌐 HTTP server listening on %s", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("❌ HTTP server error: %v", err)
+		}
+	}()
+
+	return server
+}
+
+// handleLanguages returns DescribeLanguages as JSON
+func handleLanguages(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"languages": DescribeLanguages(),
+	})
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("⚠️  Failed to write HTTP response: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// ============================================
+// Idle Output Timeout
+// ============================================
+// A program that hangs (on a lock, a sleep, ...) without printing holds
+// its container until the wall-clock timeout. With IDLE_TIMEOUT set
+// (e.g. IDLE_TIMEOUT=2s, default 0 = off), output is always streamed
+// and a program that writes nothing to stdout or stderr for that long is
+// killed with status "idle_timeout", keeping whatever it printed before.
+//
+// The idle clock starts when the container starts, so a program that
+// computes silently for longer than IDLE_TIMEOUT is killed too; keep it
+// well above the expected time to first output.
+// ============================================
+
+var idleTimeout = getEnvDuration("IDLE_TIMEOUT", 0)
+
+// idleCheckInterval caps how late an idle program is noticed
+const idleCheckInterval = 100 * time.Millisecond
+
+// watchIdle returns a channel that is closed once the capture has seen
+// no output for timeout. Watching stops when ctx is done.
+func (c *outputCapture) watchIdle(ctx context.Context, timeout time.Duration) <-chan struct{} {
+	idle := make(chan struct{})
+	c.lastOutput.Store(time.Now().UnixNano())
+
+	go func() {
+		ticker := time.NewTicker(min(idleCheckInterval, timeout))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if now.Sub(time.Unix(0, c.lastOutput.Load())) >= timeout {
+					close(idle)
+					return
+				}
+			}
+		}
+	}()
+	return idle
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ============================================
+// Image Digest Pinning
+// ============================================
+// Tags like python:3.9-alpine are mutable: a compromised upstream tag
+// could swap in a malicious image. A language can instead be pinned to
+// an immutable digest, either in LanguageConfig.ImageDigest or via
+// IMAGE_DIGEST_<LANGUAGE> (e.g. IMAGE_DIGEST_PYTHON=sha256:...).
+//
+// Pinned images are pulled and run as repo@digest, and the local image
+// is verified against the pin before every execution. A mismatch fails
+// the job (fail closed). Unpinned languages keep pulling by tag, which
+// is convenient for development; set REQUIRE_PINNED_IMAGES=true to
+// refuse to start with any unpinned language.
+//
+// Either way, every result records the digest of the image that ran it
+// (ImageDigest), for reproducing or auditing a run later.
+// ============================================
+
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// loadImagePins applies digest overrides from the environment and validates all pins.
+// Must be called before the worker loop starts, as it may update languageMap.
+func loadImagePins() error {
+	requirePinned := getEnvBool("REQUIRE_PINNED_IMAGES", false)
+
+	for name, langConfig := range languageMap {
+		envKey := "IMAGE_DIGEST_" + strings.ToUpper(name)
+		if digest := getEnv(envKey, ""); digest != "" {
+			langConfig.ImageDigest = digest
+			languageMap[name] = langConfig
+		}
+
+		if langConfig.ImageDigest == "" {
+			if requirePinned {
+				return fmt.Errorf("language %s has no pinned image digest (set %s)", name, envKey)
+			}
+			continue
+		}
+
+		if !digestPattern.MatchString(langConfig.ImageDigest) {
+			return fmt.Errorf("invalid image digest for %s: %q (expected sha256:<64 hex>)", name, langConfig.ImageDigest)
+		}
+		log.Printf("📌 [%s] Image pinned: %s", name, imageReference(langConfig))
+	}
+
+	return nil
+}
+
+// imageReference returns the image reference to pull and run for a language:
+// repo@digest when pinned, otherwise the configured tag
+func imageReference(langConfig LanguageConfig) string {
+	if langConfig.ImageDigest == "" {
+		return langConfig.Image
+	}
+	return imageRepository(langConfig.Image) + "@" + langConfig.ImageDigest
+}
+
+// imageRepository strips the tag (and any digest) from an image reference
+func imageRepository(ref string) string {
+	if at := strings.Index(ref, "@"); at >= 0 {
+		ref = ref[:at]
+	}
+	// A colon after the last slash is a tag; one before it is a registry port
+	if colon := strings.LastIndex(ref, ":"); colon > strings.LastIndex(ref, "/") {
+		ref = ref[:colon]
+	}
+	return ref
+}
+
+// resolveImageDigest returns the exact image behind ref, so a result
+// records what ran even when ref is a floating tag: the repo digest for
+// ref's repository if the image came from a registry, otherwise its ID.
+// Inspection failures are logged and leave the digest empty.
+func (dp *DockerProvider) resolveImageDigest(ctx context.Context, jobID, ref string) string {
+	info, _, err := dp.client.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to resolve image digest for %s: %v", jobID, ref, err)
+		return ""
+	}
+
+	repository := imageRepository(ref)
+	for _, repoDigest := range info.RepoDigests {
+		if imageRepository(repoDigest) == repository {
+			return repoDigest
+		}
+	}
+	return info.ID
+}
+
+// verifyImageDigest checks that the local image ref carries the expected repo digest
+func (dp *DockerProvider) verifyImageDigest(ctx context.Context, ref, digest string) error {
+	info, _, err := dp.client.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+
+	for _, repoDigest := range info.RepoDigests {
+		if at := strings.Index(repoDigest, "@"); at >= 0 && repoDigest[at+1:] == digest {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("image digest mismatch for %s: expected %s, got %v", ref, digest, info.RepoDigests)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// ============================================
+// Image Pull Progress
+// ============================================
+// Pulling a large image (e.g. a full JDK) on first use can take
+// minutes, so pulls get their own PULL_TIMEOUT (default 5m) instead of
+// counting against the execution timeout, which only starts once the
+// image is present.
+//
+// While a pull runs its progress is logged every PULL_PROGRESS_INTERVAL
+// (default 10s), so operators can tell a slow pull from a hung one.
+// ============================================
+
+var (
+	pullTimeout          = getEnvDuration("PULL_TIMEOUT", 5*time.Minute)
+	pullProgressInterval = getEnvDuration("PULL_PROGRESS_INTERVAL", 10*time.Second)
+)
+
+// pullMessage is one line of the daemon's JSON pull output
+type pullMessage struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Progress struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// layerProgress tracks the download of one image layer
+type layerProgress struct {
+	current, total int64
+	done           bool
+}
+
+// followPull consumes a pull's output, logging progress periodically.
+// It returns the error reported in the stream, if any.
+func followPull(imageName string, reader io.Reader) error {
+	layers := make(map[string]*layerProgress)
+	var order []string
+	lastLog := time.Now()
+
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg pullMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error reading pull output: %w", err)
+		}
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+
+		// Every message with an ID is about a layer, except "Pulling from <repo>" (ID is the tag)
+		if msg.ID != "" && !strings.HasPrefix(msg.Status, "Pulling from") {
+			layer, ok := layers[msg.ID]
+			if !ok {
+				layer = &layerProgress{}
+				layers[msg.ID] = layer
+				order = append(order, msg.ID)
+			}
+			switch msg.Status {
+			case "Downloading":
+				layer.current, layer.total = msg.Progress.Current, msg.Progress.Total
+			case "Pull complete", "Already exists":
+				layer.done = true
+			}
+		}
+
+		if pullProgressInterval > 0 && time.Since(lastLog) >= pullProgressInterval {
+			lastLog = time.Now()
+			var current, total int64
+			done := 0
+			for _, id := range order {
+				layer := layers[id]
+				current += layer.current
+				total += layer.total
+				if layer.done {
+					done++
+				}
+			}
+			log.Printf("📥 Still pulling %s: %d/%d layers done, %d/%d MB downloaded", imageName, done, len(order), current>>20, total>>20)
+		}
+	}
+}
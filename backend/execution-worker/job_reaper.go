@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ============================================
+// Stuck Job Reaper
+// ============================================
+// A worker that dies mid-execution leaves its job "processing" in
+// MongoDB forever. Every REAPER_INTERVAL (default 1m) the reaper looks
+// for jobs whose startedAt is older than STUCK_JOB_GRACE (default 10m)
+// and, depending on STUCK_JOB_ACTION:
+//
+//   fail    - (default) marks them "failed" with a "worker lost" error
+//   requeue - puts them back on the queue, counting it as a retry (see
+//             job_retry.go), so a job that keeps killing workers is
+//             eventually dead-lettered
+//
+// STUCK_JOB_GRACE must be longer than any execution can take, including
+// image pulls. Set it to 0 to disable the reaper.
+//
+// With QUEUE_ACK_MODE=at-least-once the reaper also requeues jobs from
+// the processing lists of dead workers (see reliable_queue.go).
+//
+// Every worker runs a reaper. Each job is claimed with an update that
+// only matches while it is still stuck, so only one of them acts on it.
+// ============================================
+
+var (
+	stuckJobGrace  = getEnvDuration("STUCK_JOB_GRACE", 10*time.Minute)
+	reaperInterval = getEnvDuration("REAPER_INTERVAL", time.Minute)
+	stuckJobAction = getEnv("STUCK_JOB_ACTION", "fail")
+)
+
+const (
+	reaperBatchSize = 100 // Jobs handled per scan
+	workerLostError = "worker lost during execution"
+)
+
+// stuckJob is a job document as read by the reaper
+type stuckJob struct {
+	Job       `bson:",inline"`
+	StartedAt string `bson:"startedAt"`
+}
+
+// validateJobReaper checks the reaper configuration at startup
+func validateJobReaper() error {
+	if stuckJobAction != "fail" && stuckJobAction != "requeue" {
+		return fmt.Errorf("STUCK_JOB_ACTION must be fail or requeue, got %q", stuckJobAction)
+	}
+	if stuckJobGrace > 0 && reaperInterval <= 0 {
+		return fmt.Errorf("REAPER_INTERVAL must be positive, got %v", reaperInterval)
+	}
+	return nil
+}
+
+// startJobReaper starts the reaper goroutine. The returned channel is
+// closed once it has stopped (after ctx is cancelled).
+func startJobReaper(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if stuckJobGrace <= 0 {
+		log.Println("🪦 Stuck job reaper disabled (STUCK_JOB_GRACE=0)")
+		close(done)
+		return done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(reaperInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reapStuckJobs(ctx)
+				if source, ok := jobSource.(*redisJobSource); ok && source.processing != "" {
+					recoverAbandonedJobs(ctx, source.client, source.queue)
+				}
+			}
+		}
+	}()
+
+	log.Printf("🪦 Stuck job reaper started (grace: %v, every %v, action: %s)", stuckJobGrace, reaperInterval, stuckJobAction)
+	return done
+}
+
+// reapStuckJobs fails or requeues jobs stuck in "processing" past the grace period
+func reapStuckJobs(ctx context.Context) {
+	collection := mongoDb.Collection("submissions")
+
+	// startedAt is stored as RFC3339 UTC, which sorts chronologically as a string
+	cutoff := time.Now().Add(-stuckJobGrace).UTC().Format(time.RFC3339)
+	cursor, err := collection.Find(ctx,
+		bson.M{"status": "processing", "startedAt": bson.M{"$lt": cutoff}},
+		options.Find().SetLimit(reaperBatchSize),
+	)
+	if err != nil {
+		log.Printf("⚠️  Stuck job scan failed: %v", err)
+		return
+	}
+
+	var jobs []stuckJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		log.Printf("⚠️  Stuck job scan failed: %v", err)
+		return
+	}
+
+	for _, stuck := range jobs {
+		if err := reapJob(ctx, stuck); err != nil {
+			log.Printf("⚠️  [%s] Failed to reap stuck job: %v", stuck.JobID, err)
+		}
+	}
+}
+
+// reapJob claims one stuck job and fails or requeues it
+func reapJob(ctx context.Context, stuck stuckJob) error {
+	// Only matches if nobody else has picked the job up since the scan
+	filter := bson.M{"jobId": stuck.JobID, "status": "processing", "startedAt": stuck.StartedAt}
+	result := &ExecutionResult{Output: "", Error: workerLostError, Status: "failed", QueueTime: -1, SchemaVersion: resultSchemaVersion}
+
+	update := bson.M{"status": "queued"}
+	if stuckJobAction == "fail" {
+		update = resultFields(result)
+		update["status"] = "failed"
+		update["completedAt"] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	claimed, err := mongoDb.Collection("submissions").UpdateOne(ctx, filter, bson.M{"$set": update})
+	if err != nil {
+		return err
+	}
+	if claimed.ModifiedCount == 0 {
+		return nil
+	}
+
+	log.Printf("🪦 [%s] Stuck in processing since %s (action: %s)", stuck.JobID, stuck.StartedAt, stuckJobAction)
+	if stuckJobAction == "requeue" {
+		requeueJob(stuck.Job, workerLostError, -1)
+		return nil
+	}
+	publishJobResult(ctx, stuck.Job, result)
+	enqueueWebhook(ctx, stuck.Job, result)
+	return nil
+}
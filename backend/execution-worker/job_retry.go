@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================
+// Job Retries
+// ============================================
+// A job interrupted by a transient failure (the provider couldn't run
+// it, or the worker is shutting down mid-execution) is requeued rather
+// than failed. With the Redis job source, requeued jobs are pushed to the
+// FRONT of the submission queue so they don't wait behind fresh work a
+// second time (see JobSource.Requeue).
+//
+// Each requeue increments the job's retryCount. Once it exceeds
+// MAX_JOB_RETRIES (default 3), the job is moved to the dead-letter queue
+// and marked failed, so a job that reliably breaks the worker can't
+// loop forever.
+//
+// Payloads over MAX_PAYLOAD_BYTES are dead-lettered unparsed (see
+// processJob), with only their start kept for inspection.
+// ============================================
+
+var maxJobRetries = getEnvInt("MAX_JOB_RETRIES", 3)
+
+// deadLetterPreviewBytes is how much of an unparsed payload is dead-lettered
+const deadLetterPreviewBytes = 1024
+
+// deadLetter is the payload stored on the dead-letter queue
+type deadLetter struct {
+	Job      *Job   `json:"job,omitempty"`
+	Reason   string `json:"reason"`
+	FailedAt string `json:"failedAt"`
+
+	// Set instead of Job for payloads that were never parsed
+	Payload      string `json:"payload,omitempty"` // Start of the raw payload
+	PayloadBytes int    `json:"payloadBytes,omitempty"`
+}
+
+// requeueJob puts an interrupted job back on the queue, or
+// dead-letters it once it has used up its retries. It uses its own
+// context because the worker's may already be cancelled by shutdown.
+func requeueJob(job Job, reason string, queueTime time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	recordInterruptedExecution(ctx, job, reason) // See execution_id.go
+	job.RetryCount++
+	if job.RetryCount > maxJobRetries {
+		log.Printf("💀 [%s] Giving up after %d attempts: %s", job.JobID, job.RetryCount, reason)
+		deadLetterJob(ctx, job, reason)
+		rejectJob(ctx, job, "failed", fmt.Sprintf("Execution failed after %d attempts: %s", job.RetryCount, reason), queueTime)
+		return
+	}
+
+	data, err := json.Marshal(job.withQueuedStdin())
+	if err != nil {
+		log.Printf("❌ [%s] Failed to marshal job for requeue: %v", job.JobID, err)
+		rejectJob(ctx, job, "failed", reason, queueTime)
+		return
+	}
+
+	if err := jobSource.Requeue(ctx, data); err != nil {
+		log.Printf("❌ [%s] Failed to requeue job: %v", job.JobID, err)
+		rejectJob(ctx, job, "failed", reason, queueTime)
+		return
+	}
+
+	// The count is stored too, so a job requeued by the stuck job reaper keeps it
+	_, err = mongoDb.Collection("submissions").UpdateOne(ctx,
+		bson.M{"jobId": job.JobID},
+		bson.M{"$set": bson.M{"status": "queued", "retryCount": job.RetryCount}},
+	)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to reset status to queued: %v", job.JobID, err)
+	}
+	log.Printf("🔁 [%s] Requeued (retry %d/%d): %s", job.JobID, job.RetryCount, maxJobRetries, reason)
+}
+
+// deadLetterJob records a job that exhausted its retries for later inspection
+func deadLetterJob(ctx context.Context, job Job, reason string) {
+	redacted := redactJob(job.withQueuedStdin())
+	pushDeadLetter(ctx, job.JobID, deadLetter{
+		Job:      &redacted,
+		Reason:   reason,
+		FailedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// deadLetterPayload records a payload that was rejected before parsing
+func deadLetterPayload(ctx context.Context, payload string, reason string) {
+	preview := payload
+	if len(preview) > deadLetterPreviewBytes {
+		preview = preview[:deadLetterPreviewBytes]
+	}
+	if !persistCode || !persistStdin {
+		preview = "" // Unparsed, so the redacted fields can't be picked out
+	}
+	pushDeadLetter(ctx, "-", deadLetter{
+		Reason:       reason,
+		FailedAt:     time.Now().UTC().Format(time.RFC3339),
+		Payload:      preview,
+		PayloadBytes: len(payload),
+	})
+}
+
+// pushDeadLetter appends an entry to the dead-letter queue
+func pushDeadLetter(ctx context.Context, jobID string, entry deadLetter) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("❌ [%s] Failed to marshal dead letter: %v", jobID, err)
+		return
+	}
+
+	if err := redisClient.RPush(ctx, deadLetterQueue, data).Err(); err != nil {
+		log.Printf("❌ [%s] Failed to push to dead-letter queue: %v", jobID, err)
+	}
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -16,10 +18,19 @@ import (
 )
 
 const (
-	serviceName     = "execution-worker"
-	version         = "4.0.0"
-	submissionQueue = "submission_queue"
-	analysisChannel = "analysis_queue" // Pub/Sub channel for analysis worker
+	serviceName = "execution-worker"
+
+	resultChannelPrefix = "result:" // Per-job Pub/Sub channel for final results
+)
+
+// version is the worker version, overridable at build time with -ldflags "-X main.version=..."
+var version = "4.0.0"
+
+// Queue and channel names, configurable so isolated instances can share one Redis (see job_source.go)
+var (
+	submissionQueue = getEnv("SUBMISSION_QUEUE", "submission_queue")
+	analysisChannel = getEnv("ANALYSIS_CHANNEL", "analysis_queue") // Pub/Sub channel for analysis worker
+	deadLetterQueue = getEnv("DEAD_LETTER_QUEUE", submissionQueue+":dead")
 )
 
 // Job represents the structure shared with the API Gateway
@@ -29,14 +40,68 @@ type Job struct {
 	Language    string `json:"language" bson:"language"`
 	Code        string `json:"code" bson:"code"`
 	SubmittedAt string `json:"submittedAt" bson:"submittedAt"`
+
+	// Multi-file submissions: relative path -> content. Code, if set, is
+	// written as the default "script<ext>" file alongside these.
+	Files map[string]string `json:"files,omitempty" bson:"files,omitempty"`
+	// EntryPoint overrides which file (or, for Python, which package) is run
+	EntryPoint string `json:"entryPoint,omitempty" bson:"entryPoint,omitempty"`
+	// Profile selects a named resource profile (default "small")
+	Profile string `json:"profile,omitempty" bson:"profile,omitempty"`
+	// Seed, when set, is exposed to the program as RCE_SEED for deterministic runs
+	Seed *int64 `json:"seed,omitempty" bson:"seed,omitempty"`
+	// CallbackURL receives the final result as a POST (host must be allowlisted)
+	CallbackURL string `json:"callbackUrl,omitempty" bson:"callbackUrl,omitempty"`
+	// ArtifactPaths are patterns for files in /output to store as artifacts
+	ArtifactPaths []string `json:"artifactPaths,omitempty" bson:"artifactPaths,omitempty"`
+	// Stdin is the program's input; StdinRef instead names a Redis key holding it (for large inputs)
+	Stdin    string `json:"stdin,omitempty" bson:"stdin,omitempty"`
+	StdinRef string `json:"stdinRef,omitempty" bson:"stdinRef,omitempty"`
+	// StdinParts is the input in pieces, concatenated in order (instead of Stdin or StdinRef)
+	StdinParts []string `json:"stdinParts,omitempty" bson:"stdinParts,omitempty"`
+	// UseHarness wraps Code in the language's HarnessTemplate (submit only a solution function)
+	UseHarness bool `json:"useHarness,omitempty" bson:"useHarness,omitempty"`
+	// Mode is "run" (default) or "eval", which also returns the value of the code's last expression (see eval_mode.go)
+	Mode string `json:"mode,omitempty" bson:"mode,omitempty"`
+	// SetupScript and TeardownScript are sh scripts run before and after the code (see setup_teardown.go)
+	SetupScript    string `json:"setupScript,omitempty" bson:"setupScript,omitempty"`
+	TeardownScript string `json:"teardownScript,omitempty" bson:"teardownScript,omitempty"`
+	// StopOnOutput kills the program at its first non-empty stdout line (debugging output buffering)
+	StopOnOutput bool `json:"stopOnOutput,omitempty" bson:"stopOnOutput,omitempty"`
+	// OutputEncoding is "utf8" (default) or "base64" for programs with binary output
+	OutputEncoding string `json:"outputEncoding,omitempty" bson:"outputEncoding,omitempty"`
+	// DeadlineUnixMs is an absolute deadline (Unix ms) the result is useless after; 0 for none
+	DeadlineUnixMs int64 `json:"deadlineUnixMs,omitempty" bson:"deadlineUnixMs,omitempty"`
+	// Metadata is opaque caller data echoed with the result (see metadata.go)
+	Metadata map[string]string `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	// AnalysisTypes selects the analyses to route the job to (see analysis_routing.go); empty for all
+	AnalysisTypes []string `json:"analysisTypes,omitempty" bson:"analysisTypes,omitempty"`
+	// RetryCount is how many times the job has been requeued after a transient failure
+	RetryCount int `json:"retryCount,omitempty" bson:"retryCount,omitempty"`
+	// Variant selects a configured A/B variant of limits or images (see variants.go); empty for DEFAULT_VARIANT
+	Variant string `json:"variant,omitempty" bson:"variant,omitempty"`
+	// ExecutionID identifies this run of the job, set by processJob and never queued (see execution_id.go)
+	ExecutionID string `json:"-" bson:"-"`
+
+	// queuedStdin is StdinRef or StdinParts as queued, once resolveStdin has replaced them
+	queuedStdin *queuedStdin
 }
 
+// Runtime configuration (read once at startup)
+var (
+	publishResults  = getEnvBool("PUBLISH_RESULTS", true)         // Publish final results to result:<jobId>
+	defaultLanguage = getEnv("DEFAULT_LANGUAGE", "")              // Applied to jobs with no language
+	maxPayloadBytes = getEnvInt("MAX_PAYLOAD_BYTES", 8*1024*1024) // Larger queue payloads are dead-lettered unparsed
+)
+
 // Global clients
 var (
-	redisClient    *redis.Client
-	mongoClient    *mongo.Client
-	mongoDb        *mongo.Database
-	dockerProvider *DockerProvider
+	redisClient       *redis.Client
+	mongoClient       *mongo.Client
+	mongoDb           *mongo.Database
+	executionProvider ExecutionProvider
+	jobSource         JobSource
+	dockerProvider    *DockerProvider // Set when the execution provider is Docker-based
 )
 
 func main() {
@@ -52,18 +117,110 @@ func main() {
 	if err := initConnections(ctx); err != nil {
 		log.Fatalf("❌ Failed to initialize connections: %v", err)
 	}
-	defer cleanup()
 
-	// Initialize Docker provider
+	// Initialize execution provider
 	var err error
-	dockerProvider, err = NewDockerProvider()
+	executionProvider, err = NewExecutionProvider(getEnv("EXECUTION_PROVIDER", "docker"))
 	if err != nil {
-		log.Fatalf("❌ Failed to initialize Docker provider: %v", err)
+		log.Fatalf("❌ Failed to initialize execution provider: %v", err)
 	}
-	defer dockerProvider.Close()
-	log.Println("✅ Docker provider initialized")
+	log.Printf("✅ Execution provider initialized: %s", executionProvider.Name())
 	log.Printf("🐳 Supported languages: %v", GetSupportedLanguages())
 
+	if defaultLanguage != "" {
+		if !IsLanguageSupported(defaultLanguage) {
+			log.Fatalf("❌ DEFAULT_LANGUAGE %q is not a supported language", defaultLanguage)
+		}
+		log.Printf("🔤 Default language: %s", defaultLanguage)
+	}
+
+	if err := loadResourceProfiles(); err != nil {
+		log.Fatalf("❌ Invalid resource profile configuration: %v", err)
+	}
+
+	if err := loadVariants(); err != nil {
+		log.Fatalf("❌ Invalid variant configuration: %v", err)
+	}
+
+	if err := validateEntrypointTemplates(); err != nil {
+		log.Fatalf("❌ Invalid entrypoint template: %v", err)
+	}
+
+	if err := validateExecutors(); err != nil {
+		log.Fatalf("❌ Invalid executor: %v", err)
+	}
+
+	if err := loadCompileTimeouts(); err != nil {
+		log.Fatalf("❌ Invalid compile timeout: %v", err)
+	}
+
+	if err := loadMemorySwapRatios(); err != nil {
+		log.Fatalf("❌ Invalid memory swap ratio: %v", err)
+	}
+
+	if err := loadOutputFilters(); err != nil {
+		log.Fatalf("❌ Invalid output filter: %v", err)
+	}
+
+	if err := loadPreprocessors(); err != nil {
+		log.Fatalf("❌ Invalid preprocessor configuration: %v", err)
+	}
+
+	if err := validateCodeDelivery(); err != nil {
+		log.Fatalf("❌ Invalid code delivery: %v", err)
+	}
+
+	if err := validateProcessingStatusFailure(); err != nil {
+		log.Fatalf("❌ Invalid status write configuration: %v", err)
+	}
+
+	if err := validateQueueNames(); err != nil {
+		log.Fatalf("❌ Invalid queue configuration: %v", err)
+	}
+	if err := validateQueueAckMode(); err != nil {
+		log.Fatalf("❌ Invalid queue configuration: %v", err)
+	}
+	log.Printf("📬 Queues: submissions=%s analysis=%s dead-letter=%s", submissionQueue, analysisChannel, deadLetterQueue)
+
+	if err := loadAnalysisRoutes(); err != nil {
+		log.Fatalf("❌ Invalid ANALYSIS_ROUTES: %v", err)
+	}
+	if err := validateAnalysisNotify(); err != nil {
+		log.Fatalf("❌ Invalid analysis notification configuration: %v", err)
+	}
+
+	if err := validateJobReaper(); err != nil {
+		log.Fatalf("❌ Invalid stuck job reaper configuration: %v", err)
+	}
+
+	if err := validateExtraMounts(); err != nil {
+		log.Fatalf("❌ Invalid extra mount: %v", err)
+	}
+
+	// Apply and validate image digest pins
+	if err := loadImagePins(); err != nil {
+		log.Fatalf("❌ Invalid image pinning configuration: %v", err)
+	}
+
+	if err := loadRegistryAuth(); err != nil {
+		log.Fatalf("❌ Invalid registry credentials: %v", err)
+	}
+
+	if dp, ok := executionProvider.(*DockerProvider); ok {
+		dockerProvider = dp
+
+		// Build warmed images for languages that define one
+		dockerProvider.PrepareWarmImages(ctx)
+
+		if err := dockerProvider.loadPackageCaches(ctx); err != nil {
+			log.Fatalf("❌ Invalid package cache configuration: %v", err)
+		}
+
+		if err := dockerProvider.loadCPUPinning(ctx); err != nil {
+			log.Fatalf("❌ Invalid CPU pinning configuration: %v", err)
+		}
+	}
+
 	// Ensure execution volume exists
 	if err := os.MkdirAll(ExecutionVolume, 0755); err != nil {
 		log.Printf("⚠️  Warning: Could not create execution volume at %s: %v", ExecutionVolume, err)
@@ -71,23 +228,125 @@ func main() {
 		log.Printf("📁 Execution volume ready: %s", ExecutionVolume)
 	}
 
+	// Prove each language actually runs in the sandbox before taking jobs
+	if runSelfTest {
+		log.Println("🧪 Running language self-tests...")
+		if err := runSelfTests(ctx); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
+
+	if runSandboxDiagnostic {
+		log.Println("🩺 Running sandbox diagnostics...")
+		runSandboxDiagnostics(ctx)
+	}
+
+	// Initialize the job queue
+	jobSource, err = NewJobSource(getEnv("QUEUE_BACKEND", "redis"))
+	if err != nil {
+		log.Fatalf("❌ Failed to initialize job source: %v", err)
+	}
+	log.Printf("✅ Job source initialized: %s", jobSource.Name())
+
+	// Start the HTTP API (language descriptions)
+	httpServer := startHTTPServer(getEnv("HTTP_ADDR", ":8081"))
+
 	// Graceful shutdown handling
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start the worker loop in a goroutine
-	go workerLoop(ctx)
+	// Start webhook delivery workers
+	webhooks = startWebhookDispatcher()
+
+	// Fail or requeue jobs left "processing" by workers that died
+	reaperDone := startJobReaper(ctx)
+
+	// Expose queue backlogs for autoscaling
+	queueDepthDone := startQueueDepthSampler(ctx)
+
+	// Remove containers kept for debugging once they expire
+	debugSweeperDone := startDebugSweeper(ctx, dockerProvider)
+
+	// Count finished jobs per language and status in MongoDB
+	stats := startStatsFlusher()
+
+	// Notify the analysis worker without holding up the next job
+	notifier = startAnalysisNotifier()
+
+	// Startup configuration is complete: fingerprint it for results
+	computeConfigHash()
+
+	drain := make(chan os.Signal, 1)
+	if len(drainSignals) > 0 {
+		signal.Notify(drain, drainSignals...)
+	}
+
+	// Start the worker loop in a goroutine. Cancelling pullCtx stops it
+	// taking new jobs; cancelling ctx also aborts the in-flight one.
+	pullCtx, stopPulling := context.WithCancel(ctx)
+	defer stopPulling()
+	loopDone := make(chan struct{})
+	go func() {
+		defer close(loopDone)
+		workerLoop(ctx, pullCtx)
+	}()
+	ready.Store(true)
+
+	// Wait for shutdown or drain signal
+	select {
+	case sig := <-quit:
+		log.Printf("🛑 Received signal %v, shutting down gracefully...", sig)
+	case sig := <-drain:
+		log.Printf("🚰 Received signal %v, draining: finishing in-flight job, accepting no new ones...", sig)
+		ready.Store(false)
+		stopPulling()
+		select {
+		case <-loopDone:
+			log.Println("🚰 Drain complete")
+		case sig := <-quit:
+			log.Printf("🛑 Received signal %v while draining, shutting down now...", sig)
+		}
+	}
+	ready.Store(false)
 
-	// Wait for shutdown signal
-	sig := <-quit
-	log.Printf("🛑 Received signal %v, shutting down gracefully...", sig)
-	cancel() // Cancel context to stop worker loop
-	time.Sleep(2 * time.Second) // Give time for cleanup
-	log.Println("👋 Worker shutdown complete")
+	runShutdown([]shutdownStep{
+		{"execution containers", func(shutdownCtx context.Context) error {
+			stopPulling() // No new job may start a container now
+			if dockerProvider == nil {
+				return nil
+			}
+			return dockerProvider.StopRunning(shutdownCtx)
+		}},
+		{"worker loop", func(shutdownCtx context.Context) error {
+			cancel() // Aborts (and requeues) the in-flight job
+			return waitFor(shutdownCtx, loopDone)
+		}},
+		{"analysis notifications", notifier.Stop},
+		{"execution stats", stats.Stop},
+		{"HTTP server", httpServer.Shutdown},
+		{"webhook dispatcher", webhooks.Stop},
+		{"job reaper", func(shutdownCtx context.Context) error {
+			return waitFor(shutdownCtx, reaperDone)
+		}},
+		{"queue depth sampler", func(shutdownCtx context.Context) error {
+			return waitFor(shutdownCtx, queueDepthDone)
+		}},
+		{"debug container sweeper", func(shutdownCtx context.Context) error {
+			return waitFor(shutdownCtx, debugSweeperDone)
+		}},
+		{"job source", func(context.Context) error { return jobSource.Close() }},
+		{"execution provider", func(context.Context) error { return executionProvider.Close() }},
+		{"connections", cleanup},
+	})
 }
 
-// initConnections establishes connections to Redis and MongoDB
+// initConnections establishes connections to Redis and MongoDB.
+// Each connection is retried with exponential backoff so the worker waits
+// for its dependencies during startup instead of crash-looping.
 func initConnections(ctx context.Context) error {
+	attempts := getEnvInt("CONNECT_RETRY_ATTEMPTS", 10)
+	interval := getEnvDuration("CONNECT_RETRY_INTERVAL", 1*time.Second)
+
 	// Redis connection
 	redisURL := getEnv("REDIS_URL", "redis://localhost:6379")
 	log.Printf("📡 Connecting to Redis at %s", redisURL)
@@ -100,7 +359,10 @@ func initConnections(ctx context.Context) error {
 	redisClient = redis.NewClient(opt)
 
 	// Test Redis connection
-	if _, err := redisClient.Ping(ctx).Result(); err != nil {
+	err = retryWithBackoff(ctx, "Redis", attempts, interval, func() error {
+		return redisClient.Ping(ctx).Err()
+	})
+	if err != nil {
 		return err
 	}
 	log.Println("✅ Redis connected")
@@ -109,13 +371,19 @@ func initConnections(ctx context.Context) error {
 	mongoURL := getEnv("MONGO_URL", "mongodb://localhost:27017/rce-engine")
 	log.Printf("📡 Connecting to MongoDB at %s", mongoURL)
 
+	// mongo.Connect does not block on the server; the ping below does
 	mongoClient, err = mongo.Connect(ctx, options.Client().ApplyURI(mongoURL))
 	if err != nil {
 		return err
 	}
 
 	// Test MongoDB connection
-	if err := mongoClient.Ping(ctx, nil); err != nil {
+	err = retryWithBackoff(ctx, "MongoDB", attempts, interval, func() error {
+		pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return mongoClient.Ping(pingCtx, nil)
+	})
+	if err != nil {
 		return err
 	}
 	log.Println("✅ MongoDB connected")
@@ -126,37 +394,67 @@ func initConnections(ctx context.Context) error {
 	return nil
 }
 
-// workerLoop continuously listens for jobs on the Redis queue
-func workerLoop(ctx context.Context) {
-	log.Printf("👂 Worker listening on queue: %s", submissionQueue)
+// retryWithBackoff calls fn until it succeeds or attempts are exhausted,
+// doubling the wait between attempts (capped at 30s)
+func retryWithBackoff(ctx context.Context, name string, attempts int, interval time.Duration, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	wait := interval
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		log.Printf("⏳ %s not ready (attempt %d/%d): %v - retrying in %v", name, attempt, attempts, err, wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+		if wait > 30*time.Second {
+			wait = 30 * time.Second
+		}
+	}
+
+	return fmt.Errorf("%s unavailable after %d attempts: %w", name, attempts, err)
+}
+
+// workerLoop continuously listens for jobs on the job source until
+// pullCtx is cancelled. Jobs run under ctx, so a drain (pullCtx only)
+// lets the in-flight job finish.
+func workerLoop(ctx, pullCtx context.Context) {
+	log.Printf("👂 Worker listening on queue: %s", jobSource.Name())
 	log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
 	for {
 		select {
-		case <-ctx.Done():
+		case <-pullCtx.Done():
 			log.Println("🛑 Worker loop stopped")
 			return
 		default:
-			// BLPOP: Blocking pop from the left of the list
-			// This will block until a message is available or timeout
-			result, err := redisClient.BLPop(ctx, 0, submissionQueue).Result()
+			payload, ack, err := jobSource.Next(pullCtx)
 			if err != nil {
-				if err == context.Canceled {
+				if pullCtx.Err() != nil {
+					log.Println("🛑 Worker loop stopped")
 					return
 				}
-				log.Printf("❌ Redis BLPOP error: %v", err)
+				log.Printf("❌ Job source error: %v", err)
 				time.Sleep(1 * time.Second)
 				continue
 			}
 
-			// result[0] is the queue name, result[1] is the value
-			if len(result) < 2 {
-				log.Println("⚠️  Empty result from BLPOP")
-				continue
-			}
-
-			// Process the job
-			processJob(ctx, result[1])
+			// Process the job, then acknowledge it
+			processJob(ctx, payload)
+			ack()
 		}
 	}
 }
@@ -166,6 +464,14 @@ func processJob(ctx context.Context, jobData string) {
 	log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	log.Printf("📨 Received job data: %s", truncate(jobData, 200))
 
+	// Check the size before parsing: the code size limits only apply after unmarshalling
+	if maxPayloadBytes > 0 && len(jobData) > maxPayloadBytes {
+		reason := fmt.Sprintf("payload of %d bytes exceeds the %d byte limit", len(jobData), maxPayloadBytes)
+		log.Printf("❌ Rejected job: %s", reason)
+		deadLetterPayload(ctx, jobData, reason)
+		return
+	}
+
 	// 1. Unmarshal the JSON
 	var job Job
 	if err := json.Unmarshal([]byte(jobData), &job); err != nil {
@@ -173,66 +479,211 @@ func processJob(ctx context.Context, jobData string) {
 		return
 	}
 
+	// Jobs with no language get the default; explicit unsupported ones are still rejected
+	if job.Language == "" && defaultLanguage != "" {
+		job.Language = defaultLanguage
+	}
+	job.Language = canonicalLanguage(job.Language) // "py" -> "python", etc.
+
 	log.Printf("⚡ Processing Job [%s] for Language: [%s]", job.JobID, job.Language)
 	log.Printf("📝 Code preview: %s", truncate(job.Code, 100))
 
-	// 2. Update MongoDB status to "processing"
-	if err := updateJobStatus(ctx, job.JobID, "processing", nil); err != nil {
-		log.Printf("❌ Failed to update status to processing: %v", err)
+	queueTime := queueDuration(job, time.Now())
+
+	// Nobody is waiting for a job whose deadline has passed
+	if job.pastDeadline(time.Now()) {
+		log.Printf("⌛ [%s] Deadline passed before execution, not running", job.JobID)
+		rejectJob(ctx, job, "expired", "Deadline passed before execution started", queueTime)
 		return
 	}
-	log.Printf("📊 Job [%s] status updated to: processing", job.JobID)
 
-	// 3. Execute code in Docker container
-	log.Printf("🐳 [%s] Starting Docker execution...", job.JobID)
-	result, err := dockerProvider.ExecuteCode(ctx, job.Language, job.Code, job.JobID)
-	if err != nil {
-		log.Printf("❌ [%s] Docker execution error: %v", job.JobID, err)
-		updateJobStatus(ctx, job.JobID, "failed", &ExecutionResult{
-			Output: "",
-			Error:  err.Error(),
-			Status: "failed",
-		})
+	// 2. Update MongoDB status to "processing" (see status_writes.go on failure)
+	if !markProcessing(ctx, job, queueTime) {
+		return
+	}
+
+	// 3. Don't spend a container on a blank submission
+	if !hasRunnableCode(job) {
+		log.Printf("⚠️  [%s] Rejected: no code submitted", job.JobID)
+		rejectJob(ctx, job, "failed", "No code submitted", queueTime)
+		return
+	}
+
+	if err := validateMetadata(job.Metadata); err != nil {
+		log.Printf("⚠️  [%s] Rejected: %v", job.JobID, err)
+		job.Metadata = nil // Not echoed back
+		rejectJob(ctx, job, "failed", err.Error(), queueTime)
+		return
+	}
+	if err := storeMetadata(ctx, job); err != nil {
+		log.Printf("⚠️  [%s] Failed to store metadata: %v", job.JobID, err)
+	}
+
+	if err := validateOutputEncoding(job.OutputEncoding); err != nil {
+		log.Printf("⚠️  [%s] Rejected: %v", job.JobID, err)
+		rejectJob(ctx, job, "failed", err.Error(), queueTime)
+		return
+	}
+
+	if err := resolveStdin(ctx, &job); err != nil {
+		log.Printf("⚠️  [%s] Rejected: %v", job.JobID, err)
+		rejectJob(ctx, job, "failed", err.Error(), queueTime)
 		return
 	}
 
-	// 4. Log execution results
+	// 4. Execute code in the sandbox, unless an identical job was just run
+	result := cachedResult(job)
+	if result != nil {
+		log.Printf("♻️  [%s] Serving cached result", job.JobID)
+	} else {
+		if !reserveExecution(ctx, job) {
+			rejectJob(ctx, job, "quota_exceeded", "Daily execution quota exceeded, try again tomorrow", queueTime)
+			return
+		}
+
+		job.ExecutionID = newExecutionID()
+		log.Printf("🐳 [%s] Starting execution %s...", job.JobID, job.ExecutionID)
+		var err error
+		result, err = executionProvider.ExecuteCode(ctx, job)
+		if ctx.Err() != nil {
+			// Shutting down mid-execution: the result (if any) is not the program's fault
+			requeueJob(job, "worker shut down during execution", queueTime)
+			return
+		}
+		if err != nil {
+			// Provider errors are infrastructure failures, not program failures
+			log.Printf("❌ [%s] Execution error: %v", job.JobID, err)
+			requeueJob(job, err.Error(), queueTime)
+			return
+		}
+		result.ExecutionID = job.ExecutionID
+		encodeOutput(result, job.OutputEncoding)
+		cacheResult(job, result)
+	}
+
+	result.QueueTime = queueTime
+	result.Variant = variantLabel(job)
+	result.SchemaVersion = resultSchemaVersion
+
+	// Store produced files; a storage failure doesn't fail the job
+	if len(result.Artifacts) > 0 {
+		names, err := storeArtifacts(ctx, job.JobID, result.Artifacts)
+		if err != nil {
+			log.Printf("⚠️  [%s] %v", job.JobID, err)
+		}
+		result.ArtifactNames = names
+	}
+
+	// 5. Log execution results
 	log.Printf("📊 [%s] Execution Result:", job.JobID)
+	if result.ExecutionID != "" {
+		log.Printf("   Execution ID: %s", result.ExecutionID)
+	}
 	log.Printf("   Status: %s", result.Status)
 	log.Printf("   Exit Code: %d", result.ExitCode)
 	log.Printf("   Duration: %v", result.ExecutionTime)
+	if result.CPUTimeMs > 0 {
+		log.Printf("   CPU Time: %dms", result.CPUTimeMs)
+	}
+	if result.QueueTime >= 0 {
+		log.Printf("   Queue Time: %v", result.QueueTime)
+	}
 	log.Printf("   Output: %s", truncate(result.Output, 200))
 	if result.Error != "" {
 		log.Printf("   Error: %s", result.Error)
 	}
+	if result.Signal != "" {
+		log.Printf("   Signal: %s", result.Signal)
+	}
+	if result.ColdStart {
+		log.Printf("   Cold Start: image pulled")
+	}
 
-	// 5. Update MongoDB with final result
-	if err := updateJobStatus(ctx, job.JobID, result.Status, result); err != nil {
+	// 6. Update MongoDB with final result
+	if err := writeFinalStatus(ctx, job.JobID, result.Status, result); err != nil {
 		log.Printf("❌ Failed to update status to %s: %v", result.Status, err)
 		return
 	}
 
 	log.Printf("✅ Job [%s] finished with status: %s", job.JobID, result.Status)
+	recordExecution(job, result.Status)
 
-	// 6. Notify analysis worker via Redis Pub/Sub
-	if err := notifyAnalysisWorker(ctx, job); err != nil {
-		log.Printf("⚠️ Failed to notify analysis worker: %v", err)
-		// Non-fatal error - execution succeeded
-	} else {
-		log.Printf("📊 Job [%s] sent to analysis queue", job.JobID)
-	}
+	// 7. Push the result to subscribers and webhooks so they don't have to poll MongoDB
+	publishJobResult(ctx, job, result)
+	enqueueWebhook(ctx, job, result)
+
+	// 8. Notify analysis worker via Redis Pub/Sub, off the job's path (see analysis_notify.go)
+	enqueueAnalysisNotification(ctx, job, result)
 
 	log.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 }
 
+// rejectJob finishes a job with a terminal status without a successful
+// execution, and notifies result subscribers
+func rejectJob(ctx context.Context, job Job, status, message string, queueTime time.Duration) {
+	result := &ExecutionResult{
+		Output:        "",
+		Error:         message,
+		Status:        status,
+		QueueTime:     queueTime,
+		SchemaVersion: resultSchemaVersion,
+	}
+	if err := writeFinalStatus(ctx, job.JobID, status, result); err != nil {
+		log.Printf("❌ Failed to update status to %s: %v", status, err)
+		return
+	}
+	recordExecution(job, status)
+	publishJobResult(ctx, job, result)
+	enqueueWebhook(ctx, job, result)
+}
+
+// deadline returns the job's absolute deadline, if it has one
+func (job Job) deadline() (time.Time, bool) {
+	if job.DeadlineUnixMs <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(job.DeadlineUnixMs), true
+}
+
+// pastDeadline reports whether the job's deadline is at or before now
+func (job Job) pastDeadline(now time.Time) bool {
+	deadline, ok := job.deadline()
+	return ok && !now.Before(deadline)
+}
+
+// queueDuration returns how long a job waited between submission and pickup,
+// or -1 if SubmittedAt can't be parsed
+func queueDuration(job Job, startedAt time.Time) time.Duration {
+	submittedAt, err := time.Parse(time.RFC3339, job.SubmittedAt)
+	if err != nil {
+		log.Printf("⚠️  [%s] Unparseable submittedAt %q, queue time unknown: %v", job.JobID, job.SubmittedAt, err)
+		return -1
+	}
+
+	queueTime := startedAt.Sub(submittedAt)
+	if queueTime < 0 {
+		// Clock skew between the gateway and this worker
+		return 0
+	}
+	return queueTime
+}
+
 // notifyAnalysisWorker publishes a message to the analysis queue
 // for the Python analysis worker to pick up and analyze
-func notifyAnalysisWorker(ctx context.Context, job Job) error {
+func notifyAnalysisWorker(ctx context.Context, job Job, result *ExecutionResult) error {
 	// Create the message payload for the analysis worker
 	payload := map[string]interface{}{
 		"jobId":    job.JobID,
 		"language": job.Language,
-		"code":     job.Code,
+	}
+	if persistCode {
+		payload["code"] = job.Code
+	}
+	if result.ImageDigest != "" {
+		payload["imageDigest"] = result.ImageDigest
+	}
+	if len(job.Metadata) > 0 {
+		payload["metadata"] = job.Metadata
 	}
 
 	// Marshal to JSON
@@ -241,8 +692,130 @@ func notifyAnalysisWorker(ctx context.Context, job Job) error {
 		return err
 	}
 
-	// Publish to the analysis channel using Redis Pub/Sub
-	return redisClient.Publish(ctx, analysisChannel, string(data)).Err()
+	// Publish to each analysis channel using Redis Pub/Sub. Pub/Sub keeps
+	// nothing when the analysis worker is down, so Redis can't grow here.
+	for _, channel := range analysisChannels(job) {
+		if err := redisClient.Publish(ctx, channel, string(data)).Err(); err != nil {
+			return fmt.Errorf("%s: %w", channel, err)
+		}
+	}
+	return nil
+}
+
+// publishJobResult publishes a final result to the per-job result:<jobId> channel.
+// MongoDB remains the source of truth; this is a low-latency notification only.
+func publishJobResult(ctx context.Context, job Job, result *ExecutionResult) {
+	if !publishResults {
+		return
+	}
+	jobID := job.JobID
+
+	data, err := json.Marshal(resultMessage(job, result))
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to marshal result for publishing: %v", jobID, err)
+		return
+	}
+
+	if err := redisClient.Publish(ctx, resultChannelPrefix+jobID, string(data)).Err(); err != nil {
+		log.Printf("⚠️  [%s] Failed to publish result: %v", jobID, err)
+	}
+}
+
+// resultMessage is the result payload sent to subscribers and webhooks
+func resultMessage(job Job, result *ExecutionResult) bson.M {
+	message := resultFields(result)
+	message["jobId"] = job.JobID
+	message["status"] = result.Status
+	if len(job.Metadata) > 0 {
+		message["metadata"] = job.Metadata
+	}
+	return message
+}
+
+// resultFields returns the execution result fields as stored on the submission document
+func resultFields(result *ExecutionResult) bson.M {
+	fields := bson.M{
+		"output":        result.Output,
+		"executionTime": result.ExecutionTime.Milliseconds(),
+		"exitCode":      result.ExitCode,
+		"schemaVersion": result.SchemaVersion,
+	}
+
+	if result.Error != "" {
+		fields["error"] = result.Error
+	}
+	if result.Signal != "" {
+		fields["signal"] = result.Signal
+	}
+	if result.OutputEncoding != "" {
+		fields["encoding"] = result.OutputEncoding
+	}
+	if result.RawOutput != "" {
+		fields["rawOutput"] = result.RawOutput
+	}
+	if result.Stderr != "" {
+		fields["stderr"] = result.Stderr
+	}
+	if result.Truncation.Truncated {
+		fields["truncated"] = true
+		fields["truncation"] = result.Truncation
+	}
+	if result.ColdStart {
+		fields["coldStart"] = true
+	}
+	if result.CPUTimeMs > 0 {
+		fields["cpuTimeMs"] = result.CPUTimeMs
+	}
+	if result.StdinConsumed != nil {
+		fields["stdinConsumed"] = *result.StdinConsumed
+	}
+	if result.Cached {
+		fields["cached"] = true
+	}
+	if result.CachedFrom != "" {
+		fields["cachedFrom"] = result.CachedFrom
+	}
+	if len(result.ArtifactNames) > 0 {
+		fields["artifacts"] = result.ArtifactNames
+	}
+	if result.QueueTime >= 0 {
+		fields["queueTimeMs"] = result.QueueTime.Milliseconds()
+	}
+	if result.ReturnValue != "" {
+		fields["returnValue"] = result.ReturnValue
+	}
+	if result.Limits != nil {
+		fields["limits"] = result.Limits
+	}
+	if result.Lint != nil {
+		fields["lint"] = result.Lint
+	}
+	if result.ExecutionID != "" {
+		fields["executionId"] = result.ExecutionID
+	}
+	if result.Variant != "" {
+		fields["variant"] = result.Variant
+	}
+	if result.WorkerVersion != "" {
+		fields["workerVersion"] = result.WorkerVersion
+	}
+	if result.ConfigHash != "" {
+		fields["configHash"] = result.ConfigHash
+	}
+	if result.ImageDigest != "" {
+		fields["imageDigest"] = result.ImageDigest
+	}
+	if result.OutputBytesTotal > 0 {
+		fields["outputBytesTotal"] = result.OutputBytesTotal
+	}
+	if result.StderrOnly {
+		fields["stderrOnly"] = true
+	}
+	if len(result.PhaseTimings) > 0 {
+		fields["phaseTimings"] = result.PhaseTimings
+	}
+
+	return fields
 }
 
 // updateJobStatus updates the status of a job in MongoDB
@@ -252,26 +825,37 @@ func updateJobStatus(ctx context.Context, jobID string, status string, result *E
 	updateFields := bson.M{
 		"status": status,
 	}
+	var unsetFields, pushFields bson.M
 
 	// Add timestamp fields based on status
-	if status == "processing" {
+	switch status {
+	case "processing":
 		updateFields["startedAt"] = time.Now().UTC().Format(time.RFC3339)
-	} else if status == "completed" || status == "failed" || status == "timeout" {
+	case "queued":
+		// Requeued for a retry; nothing has finished yet
+	default:
+		// Any other status is terminal (completed, failed, timeout, cpu_limit_exceeded, ...)
 		updateFields["completedAt"] = time.Now().UTC().Format(time.RFC3339)
-		
-		// Add execution results if provided
+		unsetFields = redactedFields() // See redaction.go
+
+		// Add execution results if provided, with the build and config that produced them
 		if result != nil {
-			updateFields["output"] = result.Output
-			updateFields["executionTime"] = result.ExecutionTime.Milliseconds()
-			updateFields["exitCode"] = result.ExitCode
-			
-			if result.Error != "" {
-				updateFields["error"] = result.Error
+			result.WorkerVersion = version
+			result.ConfigHash = configHash
+			for key, value := range resultFields(result) {
+				updateFields[key] = value
 			}
 		}
+		pushFields = executionEventPush(status, result) // See execution_id.go
 	}
 
 	update := bson.M{"$set": updateFields}
+	if len(unsetFields) > 0 {
+		update["$unset"] = unsetFields
+	}
+	if len(pushFields) > 0 {
+		update["$push"] = pushFields
+	}
 
 	_, err := collection.UpdateOne(
 		ctx,
@@ -284,12 +868,46 @@ func updateJobStatus(ctx context.Context, jobID string, status string, result *E
 
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
+	if value, exists := lookupEnv(key); exists { // Recorded for the config hash (see build_info.go)
 		return value
 	}
 	return defaultValue
 }
 
+// getEnvBool retrieves a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, exists := lookupEnv(key); exists {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+		log.Printf("⚠️  Invalid boolean for %s=%q, using default %t", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvInt retrieves an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value, exists := lookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("⚠️  Invalid integer for %s=%q, using default %d", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
+// getEnvDuration retrieves a duration environment variable (e.g. "500ms", "2s")
+// or returns a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := lookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("⚠️  Invalid duration for %s=%q, using default %v", key, value, defaultValue)
+	}
+	return defaultValue
+}
+
 // truncate limits a string to maxLen characters
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -299,7 +917,7 @@ func truncate(s string, maxLen int) string {
 }
 
 // cleanup closes all connections
-func cleanup() {
+func cleanup(ctx context.Context) error {
 	log.Println("🧹 Cleaning up resources...")
 
 	if redisClient != nil {
@@ -309,10 +927,9 @@ func cleanup() {
 	}
 
 	if mongoClient != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
 		if err := mongoClient.Disconnect(ctx); err != nil {
 			log.Printf("⚠️  Error closing MongoDB: %v", err)
 		}
 	}
+	return nil
 }
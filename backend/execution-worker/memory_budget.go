@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/redis/go-redis/v9"
+)
+
+// ============================================
+// Host Memory Budget
+// ============================================
+// MAX_TOTAL_CONTAINERS bounds how many containers exist, but a few jobs
+// on a large resource profile can still commit more memory than the
+// host has. TOTAL_MEMORY_BUDGET_MB bounds the sum of the memory limits
+// of this deployment's live execution containers on the host instead.
+//
+// Before a job's containers are created, the job reserves its memory
+// limit in a Redis ledger per Docker daemon (a sorted set of
+// reservations, checked and added to atomically by a script), so two
+// workers on the host can't both claim the same headroom. The
+// reservation covers the job's lint and execution containers and is
+// released when the job's execution ends, on every path. If a worker
+// dies holding one, it expires after MEMORY_RESERVATION_TTL (default
+// 10m, longer than any execution).
+//
+// If the job's limit doesn't fit, it waits, re-checking every
+// MEMORY_BUDGET_POLL (default 500ms), until enough reservations have
+// been released or the job's context ends. The wait happens before the
+// execution timeout starts, so a job isn't charged for time spent
+// queued behind others.
+//
+// Without Redis, or if the daemon ID is unknown, the provider instead
+// sums the memory limit label every execution container carries over
+// the deployment's created, running and paused containers (exited ones,
+// e.g. kept for debugging, hold no memory). That check is best-effort:
+// two workers checking at the same moment may both fit a job into the
+// same headroom.
+//
+// A job whose limit alone exceeds the budget can never run and fails
+// with configuration_error. If listing fails the check is skipped.
+//
+// TOTAL_MEMORY_BUDGET_MB=0 (default) disables the budget.
+// ============================================
+
+// ErrMemoryBudget means a job's memory limit is larger than the whole TOTAL_MEMORY_BUDGET_MB
+var ErrMemoryBudget = errors.New("memory limit exceeds the host memory budget")
+
+var (
+	totalMemoryBudget    = int64(getEnvInt("TOTAL_MEMORY_BUDGET_MB", 0)) * 1024 * 1024
+	memoryBudgetPoll     = getEnvDuration("MEMORY_BUDGET_POLL", 500*time.Millisecond)
+	memoryReservationTTL = getEnvDuration("MEMORY_RESERVATION_TTL", 10*time.Minute)
+)
+
+// reserveMemoryScript drops expired reservations from the ledger in
+// KEYS[1], then adds ARGV[5] (expiring at ARGV[4] ms) if ARGV[2] more
+// bytes fit within the budget ARGV[3]. Members are "<bytes>:<token>".
+// It returns {1 if reserved else 0, bytes reserved by others}.
+var reserveMemoryScript = redis.NewScript(`redis.call("zremrangebyscore", KEYS[1], "-inf", ARGV[1])
+local inUse = 0
+for _, member in ipairs(redis.call("zrange", KEYS[1], 0, -1)) do
+  inUse = inUse + tonumber(string.match(member, "^(%d+):"))
+end
+if inUse + tonumber(ARGV[2]) > tonumber(ARGV[3]) then
+  return {0, inUse}
+end
+redis.call("zadd", KEYS[1], ARGV[4], ARGV[5])
+redis.call("pexpire", KEYS[1], ARGV[6])
+return {1, inUse}`)
+
+// memoryLedgerKey is the reservation ledger of a deployment on a daemon
+func memoryLedgerKey(daemonID, containerPrefix string) string {
+	return fmt.Sprintf("memory-budget:%s:%s", daemonID, containerPrefix)
+}
+
+// labelMemoryBytes records a container's memory limit for the budget
+const labelMemoryBytes = "rce.memory-bytes"
+
+// awaitMemoryBudget blocks until the given memory limit fits within
+// TOTAL_MEMORY_BUDGET_MB and reserves it, or ctx ends. The returned
+// release function must be called once the job's containers are removed.
+func (dp *DockerProvider) awaitMemoryBudget(ctx context.Context, jobID string, memoryBytes int64) (release func(), err error) {
+	noop := func() {}
+	if totalMemoryBudget <= 0 {
+		return noop, nil
+	}
+	if memoryBytes > totalMemoryBudget {
+		return noop, fmt.Errorf("%w: %dMB > %dMB", ErrMemoryBudget, memoryBytes/(1024*1024), totalMemoryBudget/(1024*1024))
+	}
+
+	var key string
+	if redisClient != nil {
+		if daemonID := dp.daemonID(ctx); daemonID != "" {
+			key = memoryLedgerKey(daemonID, dp.containerPrefix)
+		}
+	}
+	member := fmt.Sprintf("%d:%s-%s-%d", memoryBytes, workerName(), jobID, time.Now().UnixNano())
+
+	waitStart := time.Now()
+	waiting := false
+	for {
+		var inUse int64
+		var fits bool
+		if key != "" {
+			inUse, fits, err = reserveMemory(ctx, key, member, memoryBytes)
+			if err != nil {
+				log.Printf("⚠️  [%s] Failed to reserve memory, checking container labels instead: %v", jobID, err)
+				key = ""
+				continue
+			}
+		} else {
+			inUse, err = dp.memoryInUse(ctx)
+			if err != nil {
+				log.Printf("⚠️  [%s] Failed to sum container memory, skipping budget check: %v", jobID, err)
+				return noop, nil
+			}
+			fits = inUse+memoryBytes <= totalMemoryBudget
+		}
+		if fits {
+			if waiting {
+				log.Printf("🧮 [%s] Memory budget available after %v", jobID, time.Since(waitStart).Round(time.Millisecond))
+			}
+			if key == "" {
+				return noop, nil
+			}
+			return func() { releaseMemory(jobID, key, member) }, nil
+		}
+		if !waiting {
+			log.Printf("🧮 [%s] Waiting for memory: %dMB in use, %dMB needed, budget %dMB",
+				jobID, inUse/(1024*1024), memoryBytes/(1024*1024), totalMemoryBudget/(1024*1024))
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return noop, fmt.Errorf("gave up waiting for memory budget: %w", ctx.Err())
+		case <-time.After(memoryBudgetPoll):
+		}
+	}
+}
+
+// reserveMemory adds member to the ledger if memoryBytes fit, reporting
+// the bytes reserved by other jobs either way
+func reserveMemory(ctx context.Context, key, member string, memoryBytes int64) (inUse int64, reserved bool, err error) {
+	now := time.Now()
+	result, err := reserveMemoryScript.Run(ctx, redisClient, []string{key},
+		now.UnixMilli(), memoryBytes, totalMemoryBudget,
+		now.Add(memoryReservationTTL).UnixMilli(), member, memoryReservationTTL.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(result) != 2 {
+		return 0, false, fmt.Errorf("unexpected reservation reply %v", result)
+	}
+	return result[1], result[0] == 1, nil
+}
+
+// releaseMemory removes a job's reservation from the ledger
+func releaseMemory(jobID, key, member string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := redisClient.ZRem(ctx, key, member).Err(); err != nil {
+		log.Printf("⚠️  [%s] Failed to release memory reservation (expires in %v): %v", jobID, memoryReservationTTL, err)
+	}
+}
+
+// memoryInUse sums the memory limits of the deployment's live execution containers
+func (dp *DockerProvider) memoryInUse(ctx context.Context) (int64, error) {
+	args := filters.NewArgs(filters.Arg("label", labelPrefix+"="+dp.containerPrefix))
+	for _, status := range []string{"created", "running", "paused", "restarting"} {
+		args.Add("status", status)
+	}
+	list, err := dp.client.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, summary := range list {
+		bytes, err := strconv.ParseInt(summary.Labels[labelMemoryBytes], 10, 64)
+		if err != nil {
+			bytes = MemoryLimit // Created by a worker predating the label
+		}
+		total += bytes
+	}
+	return total, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ============================================
+// Memory Swap
+// ============================================
+// By default an execution container gets no swap: MemorySwap (memory
+// plus swap) equals Memory. For memory-heavy exercises a little swap
+// avoids spurious OOM kills while still bounding the total, so a
+// resource profile ("memorySwapRatio" in RESOURCE_PROFILES) or a
+// language (MEMORY_SWAP_RATIO_<LANG>) can set
+//
+//	MemorySwap = Memory * ratio
+//
+// e.g. 1.5 allows half the memory limit again in swap. A language's
+// ratio replaces its profile's. A ratio of 0 or 1 means no swap;
+// anything in between, or negative, is rejected at startup, as Docker
+// requires MemorySwap >= Memory.
+//
+// The ratio only has an effect on hosts with swap enabled. Swap is not
+// counted towards TOTAL_MEMORY_BUDGET_MB, which budgets RAM.
+// ============================================
+
+// validateSwapRatio checks that ratio keeps MemorySwap >= Memory
+func validateSwapRatio(ratio float64) error {
+	if ratio != 0 && (ratio < 1 || math.IsInf(ratio, 0) || math.IsNaN(ratio)) {
+		return fmt.Errorf("memory swap ratio must be 0 or at least 1, got %v", ratio)
+	}
+	return nil
+}
+
+// loadMemorySwapRatios applies MEMORY_SWAP_RATIO_<LANG> overrides
+func loadMemorySwapRatios() error {
+	for name, langConfig := range languageMap {
+		envKey := "MEMORY_SWAP_RATIO_" + strings.ToUpper(name)
+		raw := getEnv(envKey, "")
+		if raw == "" {
+			continue
+		}
+		ratio, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", envKey, err)
+		}
+		if err := validateSwapRatio(ratio); err != nil {
+			return fmt.Errorf("%s: %w", envKey, err)
+		}
+		langConfig.MemorySwapRatio = ratio
+		languageMap[name] = langConfig
+		log.Printf("💾 [%s] Memory swap ratio: %v", name, ratio)
+	}
+	return nil
+}
+
+// memorySwapFor returns the MemorySwap (memory + swap) limit for a
+// language under this profile
+func (p ResourceProfile) memorySwapFor(langConfig LanguageConfig) int64 {
+	ratio := p.MemorySwapRatio
+	if langConfig.MemorySwapRatio != 0 {
+		ratio = langConfig.MemorySwapRatio
+	}
+	if ratio <= 1 {
+		return p.MemoryBytes // No swap
+	}
+	return int64(float64(p.MemoryBytes) * ratio)
+}
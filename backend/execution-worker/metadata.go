@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================
+// Job Metadata Passthrough
+// ============================================
+// A job's Metadata (correlation IDs, user tags, ...) is opaque to the
+// worker: it is stored on the submission document and echoed in the
+// published result, webhook and analysis notification, so callers can
+// trace a job end to end without schema changes.
+//
+// Limits (0 disables):
+//   MAX_METADATA_ENTRIES - number of keys (default 20)
+//   MAX_METADATA_BYTES   - total size of keys and values (default 4KB)
+// ============================================
+
+var (
+	maxMetadataEntries = getEnvInt("MAX_METADATA_ENTRIES", 20)
+	maxMetadataBytes   = getEnvInt("MAX_METADATA_BYTES", 4096)
+)
+
+// validateMetadata checks a job's metadata against the limits
+func validateMetadata(metadata map[string]string) error {
+	if maxMetadataEntries > 0 && len(metadata) > maxMetadataEntries {
+		return fmt.Errorf("metadata has %d entries, limit is %d", len(metadata), maxMetadataEntries)
+	}
+
+	size := 0
+	for key, value := range metadata {
+		size += len(key) + len(value)
+	}
+	if maxMetadataBytes > 0 && size > maxMetadataBytes {
+		return fmt.Errorf("metadata is %d bytes, limit is %d", size, maxMetadataBytes)
+	}
+	return nil
+}
+
+// storeMetadata records the job's metadata on its submission document
+func storeMetadata(ctx context.Context, job Job) error {
+	if len(job.Metadata) == 0 {
+		return nil
+	}
+	_, err := mongoDb.Collection("submissions").UpdateOne(ctx,
+		bson.M{"jobId": job.JobID},
+		bson.M{"$set": bson.M{"metadata": job.Metadata}},
+	)
+	return err
+}
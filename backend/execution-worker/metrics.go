@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ============================================
+// Metrics
+// ============================================
+// A minimal in-process metrics registry, served in the Prometheus text
+// exposition format at GET /metrics. Metrics are created at package
+// level with newCounter, newGauge or newHistogram and updated from
+// anywhere in the worker.
+//
+// Labels are passed as key/value pairs:
+//   resultCacheLookups.Inc("outcome", "hit")
+//   executionPhaseSeconds.Observe(0.12, "phase", "create")
+// ============================================
+
+// metric is a named family of values, one per label set
+type metric struct {
+	name string
+	help string
+	kind string // "counter", "gauge" or "histogram"
+
+	mu     sync.Mutex
+	values map[string]float64 // Rendered label set ("" for none) -> value
+
+	buckets []float64                   // Histogram upper bounds, ascending
+	series  map[string]*histogramSeries // Rendered label set -> observations
+}
+
+// histogramSeries holds one label set's observations
+type histogramSeries struct {
+	labels []string
+	counts []uint64 // Observations per bucket (not cumulative)
+	sum    float64
+	count  uint64
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   []*metric
+)
+
+// newCounter registers a monotonically increasing metric
+func newCounter(name, help string) *metric {
+	m := &metric{name: name, help: help, kind: "counter", values: make(map[string]float64)}
+
+	metricsMu.Lock()
+	metrics = append(metrics, m)
+	metricsMu.Unlock()
+	return m
+}
+
+// newGauge registers a metric whose value is set to the latest reading
+func newGauge(name, help string) *metric {
+	m := &metric{name: name, help: help, kind: "gauge", values: make(map[string]float64)}
+
+	metricsMu.Lock()
+	metrics = append(metrics, m)
+	metricsMu.Unlock()
+	return m
+}
+
+// newHistogram registers a metric that sorts observations into buckets
+func newHistogram(name, help string, buckets []float64) *metric {
+	m := &metric{name: name, help: help, kind: "histogram", buckets: buckets, series: make(map[string]*histogramSeries)}
+
+	metricsMu.Lock()
+	metrics = append(metrics, m)
+	metricsMu.Unlock()
+	return m
+}
+
+// Observe records a histogram observation for the given label pairs
+func (m *metric) Observe(value float64, labels ...string) {
+	key := renderLabels(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	series, ok := m.series[key]
+	if !ok {
+		series = &histogramSeries{labels: labels, counts: make([]uint64, len(m.buckets))}
+		m.series[key] = series
+	}
+	for i, bound := range m.buckets {
+		if value <= bound {
+			series.counts[i]++
+			break
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+// Inc adds one to the value for the given label pairs
+func (m *metric) Inc(labels ...string) {
+	m.Add(1, labels...)
+}
+
+// Add adds delta to the value for the given label pairs
+func (m *metric) Add(delta float64, labels ...string) {
+	key := renderLabels(labels)
+
+	m.mu.Lock()
+	m.values[key] += delta
+	m.mu.Unlock()
+}
+
+// Set replaces the value for the given label pairs
+func (m *metric) Set(value float64, labels ...string) {
+	key := renderLabels(labels)
+
+	m.mu.Lock()
+	m.values[key] = value
+	m.mu.Unlock()
+}
+
+// labelEscaper escapes label values per the exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// renderLabels formats key/value pairs as a Prometheus label set
+func renderLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := labelEscaper.Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// writeMetrics writes every registered metric in the text exposition format
+func writeMetrics(w io.Writer) {
+	metricsMu.Lock()
+	registered := append([]*metric(nil), metrics...)
+	metricsMu.Unlock()
+
+	for _, m := range registered {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+		if m.kind == "histogram" {
+			m.writeHistogram(w)
+			continue
+		}
+
+		m.mu.Lock()
+		keys := make([]string, 0, len(m.values))
+		for key := range m.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s%s %g\n", m.name, key, m.values[key])
+		}
+		m.mu.Unlock()
+	}
+}
+
+// writeHistogram writes a histogram's cumulative buckets, sum and count per label set
+func (m *metric) writeHistogram(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		series := m.series[key]
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += series.counts[i]
+			le := renderLabels(append(append([]string(nil), series.labels...), "le", fmt.Sprintf("%g", bound)))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, le, cumulative)
+		}
+		le := renderLabels(append(append([]string(nil), series.labels...), "le", "+Inf"))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, le, series.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", m.name, key, series.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, key, series.count)
+	}
+}
+
+// handleMetrics serves the registry for scraping
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================
+// Output Capture
+// ============================================
+// Collects a container's demultiplexed stdout/stderr and enforces the
+// output limits while streaming, so a program that floods its output
+// never has more than the limit held in memory.
+//
+// Limits (0 disables):
+//   MAX_OUTPUT_LINES - lines across stdout and stderr (default 10000)
+//   MAX_OUTPUT_BYTES - bytes kept across stdout and stderr (default 1MB),
+//                      so output without newlines is bounded too
+//
+// A capture can also stop at the first non-empty stdout line, for jobs
+// with StopOnOutput (see watchFirstLine).
+//
+// With KILL_ON_OUTPUT_LIMIT (default true), output is always streamed
+// and the container is killed as soon as a limit is hit, with status
+// "output_limit_exceeded", instead of running on until its timeout.
+//
+// A program that writes only to stderr is flagged StderrOnly, a soft
+// signal that something may be off even when it exits 0 (e.g. a logging
+// library writing everything to stderr). It never changes the status.
+//
+// With NORMALIZE_LINE_ENDINGS=true, "\r\n" in the output becomes "\n"
+// so comparisons don't depend on the runtime's line endings; the
+// unnormalized output is kept in Raw when that changes it.
+//
+// By default the result is all of stdout followed by all of stderr.
+// With INTERLEAVE_OUTPUT=true both streams are written to one buffer in
+// the order their frames arrive, so a prompt on stdout followed by an
+// error on stderr reads as it would in a terminal. Ordering is per write
+// by the program, so it is only as fine as the program's own buffering.
+// Each stream's bytes are also kept apart, exactly as written (Stdout,
+// Stderr), for base64 output (see output_encoding.go).
+//
+// Past a limit the rest of the output is read and discarded rather than
+// kept, so the result can report how much the program actually wrote
+// (see Truncation, and outputBytesTotal on every result, which helps spot
+// output flooding). KILL_ON_OUTPUT_LIMIT chooses between the two: killed
+// at the limit (default), the count stops there; with
+// KILL_ON_OUTPUT_LIMIT=false the program runs to completion or timeout
+// and every byte it writes is counted.
+// ============================================
+
+var (
+	maxOutputLines       = getEnvInt("MAX_OUTPUT_LINES", 10000)
+	maxOutputBytes       = getEnvInt("MAX_OUTPUT_BYTES", 1024*1024)
+	normalizeLineEndings = getEnvBool("NORMALIZE_LINE_ENDINGS", false)
+	killOnOutputLimit    = getEnvBool("KILL_ON_OUTPUT_LIMIT", true)
+	interleaveOutput     = getEnvBool("INTERLEAVE_OUTPUT", false)
+)
+
+// errOutputLimit stops a stream copy once the first line has been captured
+var errOutputLimit = errors.New("output limit reached")
+
+// Truncation describes how a result's output was cut off, so consumers
+// can show e.g. "output truncated (showing X of Y bytes)"
+type Truncation struct {
+	Truncated     bool   `json:"truncated" bson:"truncated"`
+	Reason        string `json:"reason,omitempty" bson:"reason,omitempty"` // Limit that was hit: "lines" or "bytes"
+	OriginalBytes int64  `json:"originalBytes" bson:"originalBytes"`       // Bytes the program wrote to stdout and stderr
+}
+
+// capturedOutput is a container's output as shown to the user
+type capturedOutput struct {
+	Text       string // Combined stdout then stderr (or interleaved), with any truncation notice
+	Raw        string // Text before line-ending normalization, if that changed it
+	Stdout     []byte // The program's stdout as written, up to any limit, without stderr or notice
+	Stderr     string // The program's stderr as written, up to any limit
+	Truncation Truncation
+	StderrOnly bool // The program wrote to stderr but never to stdout
+}
+
+// outputCapture accumulates stdout/stderr while enforcing output limits
+type outputCapture struct {
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+
+	interleave bool            // Write stderr into stdout in arrival order
+	segments   []outputSegment // When interleaving, which stream wrote each part of stdout
+
+	maxLines int
+	maxBytes int64
+	lines    int   // Completed lines seen so far
+	kept     int64 // Bytes kept in stdout and stderr
+	total    int64 // Bytes written to either stream, including discarded ones
+	stdoutN  int64 // Bytes written to stdout alone, including discarded ones
+
+	truncated bool
+	reason    string
+	notice    string
+	limitHit  chan struct{} // Set by watchLimit; closed on truncation
+
+	lastOutput atomic.Int64 // Unix nanos of the latest write, read by watchIdle (see idle_timeout.go)
+
+	// Set by watchFirstLine; closed once stdout has a non-empty line
+	firstLine chan struct{}
+	scanned   int // Start of the first stdout line not yet checked
+	stopped   bool
+}
+
+// outputSegment is a run of interleaved output from one stream, ending
+// at end in the stdout buffer and starting where the previous one ended
+type outputSegment struct {
+	end    int
+	stdout bool
+}
+
+// newOutputCapture creates a capture with the configured limits
+func newOutputCapture() *outputCapture {
+	return &outputCapture{maxLines: maxOutputLines, maxBytes: int64(maxOutputBytes), interleave: interleaveOutput}
+}
+
+// Stdout returns the writer for the program's stdout
+func (c *outputCapture) Stdout() io.Writer { return captureWriter{c, &c.stdout, true} }
+
+// Stderr returns the writer for the program's stderr
+func (c *outputCapture) Stderr() io.Writer {
+	if c.interleave {
+		return captureWriter{c, &c.stdout, false}
+	}
+	return captureWriter{c, &c.stderr, false}
+}
+
+// Reset discards everything captured so far
+func (c *outputCapture) Reset() {
+	*c = outputCapture{maxLines: c.maxLines, maxBytes: c.maxBytes, interleave: c.interleave}
+}
+
+// watchFirstLine makes the capture stop at the first non-empty stdout
+// line and returns a channel that is closed when it does
+func (c *outputCapture) watchFirstLine() <-chan struct{} {
+	c.firstLine = make(chan struct{})
+	return c.firstLine
+}
+
+// watchLimit returns a channel that is closed when an output limit is hit
+func (c *outputCapture) watchLimit() <-chan struct{} {
+	c.limitHit = make(chan struct{})
+	return c.limitHit
+}
+
+// captureWriter routes writes for one stream into the shared capture
+type captureWriter struct {
+	capture *outputCapture
+	buf     *bytes.Buffer
+	stdout  bool // Whether this is the program's stdout (buf may be shared when interleaving)
+}
+
+func (w captureWriter) Write(p []byte) (int, error) {
+	return w.capture.write(w.buf, p, w.stdout)
+}
+
+// write appends p to buf, discarding everything from the first byte past a limit
+func (c *outputCapture) write(buf *bytes.Buffer, p []byte, stdout bool) (int, error) {
+	if c.stopped {
+		return 0, errOutputLimit
+	}
+
+	c.total += int64(len(p))
+	if stdout {
+		c.stdoutN += int64(len(p))
+	}
+	c.lastOutput.Store(time.Now().UnixNano())
+	if c.truncated {
+		return len(p), nil
+	}
+
+	if c.firstLine != nil && stdout {
+		return c.writeUntilFirstLine(p)
+	}
+
+	// Checked per write, so a single huge write without newlines is cut too
+	keep := c.keepable(p)
+	if c.maxLines > 0 {
+		for i, b := range p[:keep] {
+			if c.lines >= c.maxLines {
+				c.append(buf, p[:i], stdout)
+				c.truncate("lines", fmt.Sprintf("[output truncated: exceeded %d lines]", c.maxLines))
+				return len(p), nil
+			}
+			if b == '\n' {
+				c.lines++
+			}
+		}
+	}
+
+	c.append(buf, p[:keep], stdout)
+	if keep < len(p) {
+		c.truncateBytes()
+		return len(p), nil
+	}
+	if c.firstLine != nil && !stdout && buf == &c.stdout {
+		// Interleaved stderr never counts as the first stdout line
+		c.scanned = c.stdout.Len()
+	}
+	return len(p), nil
+}
+
+// keepable returns how much of p fits under MAX_OUTPUT_BYTES
+func (c *outputCapture) keepable(p []byte) int {
+	if c.maxBytes > 0 && c.kept+int64(len(p)) > c.maxBytes {
+		return int(c.maxBytes - c.kept)
+	}
+	return len(p)
+}
+
+// truncateBytes marks the capture as truncated by MAX_OUTPUT_BYTES
+func (c *outputCapture) truncateBytes() {
+	c.truncate("bytes", fmt.Sprintf("[output truncated: exceeded %d bytes]", c.maxBytes))
+}
+
+// append writes p to buf, recording which stream it came from when interleaving
+func (c *outputCapture) append(buf *bytes.Buffer, p []byte, stdout bool) {
+	buf.Write(p)
+	c.kept += int64(len(p))
+	if !c.interleave || len(p) == 0 {
+		return
+	}
+	if n := len(c.segments); n > 0 && c.segments[n-1].stdout == stdout {
+		c.segments[n-1].end = buf.Len()
+		return
+	}
+	c.segments = append(c.segments, outputSegment{end: buf.Len(), stdout: stdout})
+}
+
+// streams returns stdout and stderr apart, as the program wrote them
+func (c *outputCapture) streams() (stdout, stderr []byte) {
+	if !c.interleave {
+		return bytes.Clone(c.stdout.Bytes()), bytes.Clone(c.stderr.Bytes())
+	}
+
+	data := c.stdout.Bytes()
+	start := 0
+	for _, segment := range c.segments {
+		end := min(segment.end, len(data)) // writeUntilFirstLine may have cut stdout short
+		if segment.stdout {
+			stdout = append(stdout, data[start:end]...)
+		} else {
+			stderr = append(stderr, data[start:end]...)
+		}
+		start = end
+	}
+	return stdout, stderr
+}
+
+// writeUntilFirstLine appends p to stdout, cutting it off after the first non-empty line
+func (c *outputCapture) writeUntilFirstLine(p []byte) (int, error) {
+	keep := c.keepable(p)
+	c.append(&c.stdout, p[:keep], true)
+
+	data := c.stdout.Bytes()
+	for {
+		end := bytes.IndexByte(data[c.scanned:], '\n')
+		if end < 0 {
+			if keep < len(p) {
+				c.truncateBytes()
+			}
+			return len(p), nil
+		}
+		end += c.scanned
+
+		if len(bytes.TrimSpace(data[c.scanned:end])) > 0 {
+			c.stdout.Truncate(end + 1)
+			c.stopped = true
+			close(c.firstLine)
+			return len(p), errOutputLimit
+		}
+		c.scanned = end + 1
+	}
+}
+
+// truncate marks the capture as truncated by the named limit, with a notice for the user
+func (c *outputCapture) truncate(reason, notice string) {
+	c.truncated = true
+	c.reason = reason
+	c.notice = notice
+	if c.limitHit != nil {
+		close(c.limitHit)
+	}
+}
+
+// Result combines stdout and stderr (stderr last), trims trailing
+// whitespace and appends the truncation notice, if any
+func (c *outputCapture) Result() capturedOutput {
+	output := c.stdout.String()
+	if c.stderr.Len() > 0 {
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		output += c.stderr.String()
+	}
+
+	// Trim trailing whitespace
+	output = strings.TrimRight(output, "\n\r\t ")
+
+	if c.truncated {
+		if output != "" {
+			output += "\n"
+		}
+		output += c.notice
+	}
+
+	stdout, stderr := c.streams()
+	result := capturedOutput{
+		Text:   output,
+		Stdout: stdout,
+		Stderr: string(stderr),
+		Truncation: Truncation{
+			Truncated:     c.truncated,
+			Reason:        c.reason,
+			OriginalBytes: c.total,
+		},
+		StderrOnly: c.stdoutN == 0 && c.total > 0,
+	}
+	if normalizeLineEndings && strings.Contains(output, "\r\n") {
+		result.Raw = output
+		result.Text = strings.ReplaceAll(output, "\r\n", "\n")
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================
+// Output Encoding
+// ============================================
+// A job's OutputEncoding picks how its output is returned:
+//
+//   utf8   - (default) text; invalid UTF-8 bytes are replaced with U+FFFD
+//   base64 - the program's stdout bytes exactly as written, base64-
+//            encoded, for programs that write binary data
+//
+// In base64 mode nothing that processes the text output applies to the
+// encoded bytes (trimming, line-ending normalization, output filters,
+// eval return values), and neither stderr nor a truncation notice is
+// mixed in: stderr is stored apart as "stderr" text, and truncation is
+// reported by the result's truncation field as usual.
+//
+// The encoding used is stored with the result as "encoding" so
+// consumers know whether to decode it.
+// ============================================
+
+const (
+	outputEncodingUTF8   = "utf8"
+	outputEncodingBase64 = "base64"
+)
+
+// validateOutputEncoding rejects unknown encodings
+func validateOutputEncoding(encoding string) error {
+	switch encoding {
+	case "", outputEncodingUTF8, outputEncodingBase64:
+		return nil
+	}
+	return fmt.Errorf("unsupported output encoding %q (use %s or %s)", encoding, outputEncodingUTF8, outputEncodingBase64)
+}
+
+// encodeOutput converts a result's output to the requested encoding and records it
+func encodeOutput(result *ExecutionResult, encoding string) {
+	stdout := result.Stdout
+	result.Stdout = nil
+	if encoding == outputEncodingBase64 {
+		result.Output = base64.StdEncoding.EncodeToString(stdout)
+		result.RawOutput = ""
+		result.Stderr = sanitizeUTF8(result.Stderr)
+		result.OutputEncoding = outputEncodingBase64
+		return
+	}
+
+	result.Output = sanitizeUTF8(result.Output)
+	result.RawOutput = sanitizeUTF8(result.RawOutput)
+	result.Stderr = "" // Already part of Output
+	result.OutputEncoding = outputEncodingUTF8
+}
+
+// sanitizeUTF8 replaces invalid byte sequences so the output is valid text
+func sanitizeUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
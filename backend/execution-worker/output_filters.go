@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// ============================================
+// Output Filters
+// ============================================
+// Some runtimes print the same noise on every run (Node's
+// ExperimentalWarning, JVM notices on stderr) that confuses students.
+// A language's OutputFilters are regular expressions; every output line
+// matching one is removed before the result is stored. The unfiltered
+// output is kept in rawOutput when filtering removed anything.
+//
+// OUTPUT_FILTERS_<LANG> replaces a language's filters with a JSON array
+// of patterns, e.g.
+//
+//   OUTPUT_FILTERS_JAVASCRIPT='["^\\(node:\\d+\\) ExperimentalWarning: "]'
+//
+// and '[]' turns the built-in ones off. Patterns are matched against
+// each line without its line ending; anchor them (^...) so they can't
+// remove a program's own output by accident. The partial output of a
+// run that timed out is not filtered.
+// ============================================
+
+// outputFilters holds each language's compiled OutputFilters, built by loadOutputFilters
+var outputFilters = map[string][]*regexp.Regexp{}
+
+// loadOutputFilters applies OUTPUT_FILTERS_<LANG> and compiles every
+// language's filters. Must be called before the worker loop starts.
+func loadOutputFilters() error {
+	for name, langConfig := range languageMap {
+		envKey := "OUTPUT_FILTERS_" + strings.ToUpper(name)
+		if spec := getEnv(envKey, ""); spec != "" {
+			var patterns []string
+			if err := json.Unmarshal([]byte(spec), &patterns); err != nil {
+				return fmt.Errorf("%s must be a JSON array of patterns: %w", envKey, err)
+			}
+			langConfig.OutputFilters = patterns
+			languageMap[name] = langConfig
+		}
+
+		compiled := make([]*regexp.Regexp, 0, len(langConfig.OutputFilters))
+		for _, pattern := range langConfig.OutputFilters {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid output filter %q: %w", name, pattern, err)
+			}
+			compiled = append(compiled, re)
+		}
+		outputFilters[name] = compiled
+		if len(compiled) > 0 {
+			log.Printf("🧹 [%s] Filtering output lines matching %d pattern(s)", name, len(compiled))
+		}
+	}
+	return nil
+}
+
+// filterOutput removes lines matching the language's filters. The
+// unfiltered text is kept in Raw unless Raw already holds rawer output.
+func filterOutput(jobID, language string, captured *capturedOutput) {
+	filters := outputFilters[language]
+	if len(filters) == 0 || captured.Text == "" {
+		return
+	}
+
+	lines := strings.Split(captured.Text, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if matchesAnyFilter(filters, strings.TrimSuffix(line, "\r")) {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) == len(lines) {
+		return
+	}
+
+	log.Printf("🧹 [%s] Filtered %d noisy output line(s)", jobID, len(lines)-len(kept))
+	if captured.Raw == "" {
+		captured.Raw = captured.Text
+	}
+	captured.Text = strings.TrimRight(strings.Join(kept, "\n"), "\n\r\t ")
+}
+
+// matchesAnyFilter reports whether line matches one of the patterns
+func matchesAnyFilter(patterns []*regexp.Regexp, line string) bool {
+	for _, re := range patterns {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
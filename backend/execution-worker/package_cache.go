@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// ============================================
+// Offline Package Caches
+// ============================================
+// Execution containers have no network, so programs can't install
+// packages. A language can instead get a Docker volume of pre-installed
+// packages, named by PACKAGE_CACHE_<LANGUAGE> (e.g.
+// PACKAGE_CACHE_PYTHON=rce-python-packages). It is mounted read-only at
+// the language's PackageCachePath, with PackageCacheEnv set so the
+// runtime finds it.
+//
+// Building a cache volume (run once on each Docker host, with network):
+//
+//   docker volume create rce-python-packages
+//   docker run --rm -v rce-python-packages:/opt/rce-packages python:3.9-alpine \
+//       pip install --target /opt/rce-packages requests
+//
+//   docker volume create rce-node-packages
+//   docker run --rm -v rce-node-packages:/opt/rce-packages -w /opt/rce-packages \
+//       node:18-alpine npm install lodash
+//
+// Install with the same image the language runs, so compiled extensions
+// match its runtime. The volume must exist at startup or the worker
+// refuses to start.
+// ============================================
+
+// loadPackageCaches applies PACKAGE_CACHE_<LANGUAGE> and checks each cache volume exists.
+// Must be called before the worker loop starts, as it may update languageMap.
+func (dp *DockerProvider) loadPackageCaches(ctx context.Context) error {
+	for name, langConfig := range languageMap {
+		envKey := "PACKAGE_CACHE_" + strings.ToUpper(name)
+		if volumeName := getEnv(envKey, ""); volumeName != "" {
+			langConfig.PackageCache = volumeName
+			languageMap[name] = langConfig
+		}
+
+		if langConfig.PackageCache == "" {
+			continue
+		}
+		if langConfig.PackageCachePath == "" {
+			return fmt.Errorf("%s: package caches aren't supported (no PackageCachePath)", name)
+		}
+		if _, err := dp.client.VolumeInspect(ctx, langConfig.PackageCache); err != nil {
+			return fmt.Errorf("%s: package cache volume %s: %w", name, langConfig.PackageCache, err)
+		}
+		log.Printf("📦 [%s] Package cache: %s -> %s (read-only)", name, langConfig.PackageCache, langConfig.PackageCachePath)
+	}
+	return nil
+}
+
+// packageCacheMounts returns the language's package cache mount, if it has one
+func packageCacheMounts(langConfig LanguageConfig) []mount.Mount {
+	if langConfig.PackageCache == "" {
+		return nil
+	}
+	return []mount.Mount{{
+		Type:     mount.TypeVolume,
+		Source:   langConfig.PackageCache,
+		Target:   langConfig.PackageCachePath,
+		ReadOnly: true,
+	}}
+}
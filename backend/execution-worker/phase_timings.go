@@ -0,0 +1,36 @@
+package main
+
+import "time"
+
+// ============================================
+// Execution Phase Timings
+// ============================================
+// ExecuteCode times each phase of an execution, so "Docker is slow to
+// create containers" can be told apart from "the user's code is slow":
+//
+//   image  - ensuring the image is present (a pull on cold start)
+//   lint   - the language's preprocessor check, if any (see preprocessors.go)
+//   create - creating the container (after any MAX_TOTAL_CONTAINERS check)
+//   start  - starting it
+//   wait   - running the program until it exits or is killed
+//
+// The timings (ms) are stored on the result as phaseTimings, and every
+// phase is observed in the rce_execution_phase_seconds histogram.
+// Phases an execution never reached are absent.
+// ============================================
+
+var executionPhaseSeconds = newHistogram(
+	"rce_execution_phase_seconds",
+	"Time spent in each execution phase (image, lint, create, start, wait)",
+	[]float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+)
+
+// phaseTimings maps phase name to duration in ms
+type phaseTimings map[string]int64
+
+// record stores the time since start as the named phase
+func (p phaseTimings) record(phase string, start time.Time) {
+	elapsed := time.Since(start)
+	p[phase] = elapsed.Milliseconds()
+	executionPhaseSeconds.Observe(elapsed.Seconds(), "phase", phase)
+}
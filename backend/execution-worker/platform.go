@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ============================================
+// Container Platform
+// ============================================
+// On a cluster mixing architectures, the daemon default platform can
+// differ between nodes, and a locally pulled image may not match it.
+// PLATFORM (e.g. linux/amd64 or linux/arm64/v8) pins the platform that
+// images are pulled for and containers are created with, so execution
+// doesn't depend on which node runs the job. A local image for another
+// platform is pulled again.
+//
+// Unset (default) uses the daemon's native platform. If an image has no
+// variant for PLATFORM, its jobs fail with a configuration error.
+// ============================================
+
+// ErrPlatformUnavailable marks a pull that failed because the image has no variant for PLATFORM
+var ErrPlatformUnavailable = errors.New("image has no variant for the configured platform")
+
+// parsePlatform parses "os/arch[/variant]"; an empty spec means the daemon default (nil)
+func parsePlatform(spec string) (*ocispec.Platform, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	parts := strings.Split(spec, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid PLATFORM %q (expected os/arch[/variant])", spec)
+	}
+
+	platform := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// platformString formats a platform for ImagePull ("" for the daemon default)
+func platformString(platform *ocispec.Platform) string {
+	if platform == nil {
+		return ""
+	}
+	if platform.Variant != "" {
+		return platform.OS + "/" + platform.Architecture + "/" + platform.Variant
+	}
+	return platform.OS + "/" + platform.Architecture
+}
+
+// matchesPlatform reports whether a local image was built for the platform
+func matchesPlatform(platform *ocispec.Platform, os, architecture, variant string) bool {
+	if platform == nil {
+		return true
+	}
+	if platform.OS != os || platform.Architecture != architecture {
+		return false
+	}
+	return platform.Variant == "" || platform.Variant == variant
+}
+
+// isPlatformUnavailable reports whether a pull error means the image has no such platform
+func isPlatformUnavailable(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "no matching manifest for")
+}
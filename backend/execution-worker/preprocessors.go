@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// ============================================
+// Code Preprocessors (Lint Gate)
+// ============================================
+// Some exercises want code checked before it runs, e.g. by a formatter
+// in check mode or a linter. PREPROCESS_<LANG> configures a command run
+// against the submission in its own throwaway container, as JSON:
+//
+//	PREPROCESS_PYTHON='{"image":"pyfound/black:latest_release","cmd":["black","--check","--diff","{{SCRIPT}}"],"env":["XDG_CACHE_HOME=/tmp"]}'
+//
+// "{{SCRIPT}}" is replaced by the entrypoint's path; the command runs in
+// the submission's directory, which is mounted read-only, so a
+// formatter can report its diff but not rewrite the code. The container
+// gets the same sandboxing and resource profile as the execution (CPU
+// time limit, CPU pinning, init process), counts against
+// MAX_TOTAL_CONTAINERS and is stopped on shutdown like it, for at most
+// LINT_TIMEOUT (default 10s), which extends the job's deadline.
+//
+// LINT_GATE decides what a failing (non-zero exit) check does:
+//
+//   - warn (default): the job runs as usual
+//   - block: the job isn't run and ends with status "lint_failed"
+//   - off: preprocessors are never run
+//
+// The check's exit code and output are returned in the result's "lint"
+// field either way. A check that couldn't run (e.g. its image can't be
+// pulled, or it timed out) never blocks; its error is recorded instead.
+//
+// The check runs before the execution container, so for Compiled
+// languages it sees the source before the compile step. Linted jobs are
+// always delivered as files, never over stdin (see code_delivery.go).
+// ============================================
+
+var (
+	lintGate    = getEnv("LINT_GATE", "warn")
+	lintTimeout = getEnvDuration("LINT_TIMEOUT", 10*time.Second)
+)
+
+// Preprocessor is a check run against a language's submissions before execution
+type Preprocessor struct {
+	Image string   `json:"image"`
+	Cmd   []string `json:"cmd"` // "{{SCRIPT}}" is replaced by the entrypoint path
+	Env   []string `json:"env,omitempty"`
+}
+
+// LintResult is the outcome of a job's preprocessor check
+type LintResult struct {
+	Passed   bool   `json:"passed" bson:"passed"`
+	ExitCode int    `json:"exitCode" bson:"exitCode"`
+	Output   string `json:"output,omitempty" bson:"output,omitempty"`
+	Blocked  bool   `json:"blocked,omitempty" bson:"blocked,omitempty"` // The job wasn't run (LINT_GATE=block)
+	Error    string `json:"error,omitempty" bson:"error,omitempty"`     // The check couldn't run
+}
+
+// loadPreprocessors validates LINT_GATE and applies PREPROCESS_<LANG>
+func loadPreprocessors() error {
+	switch lintGate {
+	case "warn", "block", "off":
+	default:
+		return fmt.Errorf("LINT_GATE must be warn, block or off, got %q", lintGate)
+	}
+	if lintTimeout <= 0 {
+		return fmt.Errorf("LINT_TIMEOUT must be positive")
+	}
+
+	for name, langConfig := range languageMap {
+		envKey := "PREPROCESS_" + strings.ToUpper(name)
+		raw := getEnv(envKey, "")
+		if raw == "" {
+			continue
+		}
+		var preprocessor Preprocessor
+		if err := json.Unmarshal([]byte(raw), &preprocessor); err != nil {
+			return fmt.Errorf("invalid %s: %w", envKey, err)
+		}
+		if preprocessor.Image == "" || len(preprocessor.Cmd) == 0 {
+			return fmt.Errorf("%s must set image and cmd", envKey)
+		}
+		langConfig.Preprocessor = &preprocessor
+		languageMap[name] = langConfig
+		if lintGate != "off" {
+			log.Printf("🧹 [%s] Preprocessor: %v in %s (LINT_GATE=%s)", name, preprocessor.Cmd, preprocessor.Image, lintGate)
+		}
+	}
+	return nil
+}
+
+// lintEnabled reports whether the language's submissions are checked before running
+func lintEnabled(langConfig LanguageConfig) bool {
+	return lintGate != "off" && langConfig.Preprocessor != nil
+}
+
+// runPreprocessor runs the language's check against the job's written files
+func (dp *DockerProvider) runPreprocessor(ctx context.Context, job Job, langConfig LanguageConfig, entry entryPoint, profile ResourceProfile) *LintResult {
+	jobID := job.JobID
+	preprocessor := langConfig.Preprocessor
+	ctx, cancel := context.WithTimeout(ctx, lintTimeout)
+	defer cancel()
+
+	if _, err := dp.ensureImage(ctx, preprocessor.Image); err != nil {
+		log.Printf("⚠️  [%s] Preprocessor image %s unavailable: %v", jobID, preprocessor.Image, err)
+		return &LintResult{ExitCode: -1, Error: fmt.Sprintf("preprocessor image unavailable: %v", err)}
+	}
+
+	workDir := "/code/" + jobID
+	cmd := make([]string, len(preprocessor.Cmd))
+	for i, arg := range preprocessor.Cmd {
+		cmd[i] = strings.ReplaceAll(arg, scriptPlaceholder, path.Join(workDir, entry.Name))
+	}
+
+	containerID, err := dp.createContainer(ctx, jobID, dp.containerPrefix+jobID+"-lint",
+		&container.Config{
+			Image:           preprocessor.Image,
+			Cmd:             cmd,
+			WorkingDir:      workDir,
+			Env:             preprocessor.Env,
+			NetworkDisabled: true,
+			User:            "nobody",
+			Labels:          dp.containerLabels(job, profile.MemoryBytes),
+		},
+		&container.HostConfig{
+			Resources: container.Resources{
+				Memory:     profile.MemoryBytes,
+				MemorySwap: profile.MemoryBytes,
+				CPUQuota:   profile.CPUQuota,
+				CPUPeriod:  CPUPeriod,
+				CpusetCpus: dp.cpus.cpusetFor(),
+				PidsLimit:  int64Ptr(profile.PidsLimit),
+				Ulimits:    cpuTimeUlimits(profile),
+			},
+			SecurityOpt: []string{"no-new-privileges"},
+			CapDrop:     []string{"ALL"},
+			Runtime:     dp.runtime,
+			Mounts: []mount.Mount{{
+				Type:          mount.TypeVolume,
+				Source:        ExecutionVolumeName,
+				Target:        workDir,
+				ReadOnly:      true,
+				VolumeOptions: &mount.VolumeOptions{Subpath: jobID},
+			}},
+			Init: boolPtr(true), // See the execution container
+		},
+	)
+	if err != nil {
+		return &LintResult{ExitCode: -1, Error: fmt.Sprintf("failed to create preprocessor container: %v", err)}
+	}
+	defer func() {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cleanupCancel()
+		dp.running.untrack(containerID)
+		dp.removeContainer(cleanupCtx, containerID, jobID)
+	}()
+
+	if err := dp.client.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return &LintResult{ExitCode: -1, Error: fmt.Sprintf("failed to start preprocessor container: %v", err)}
+	}
+
+	statusCh, errCh := dp.client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	var exitCode int64
+	select {
+	case err := <-errCh:
+		if ctx.Err() == context.DeadlineExceeded {
+			return &LintResult{ExitCode: -1, Error: fmt.Sprintf("preprocessor timed out after %v", lintTimeout)}
+		}
+		return &LintResult{ExitCode: -1, Error: fmt.Sprintf("preprocessor wait error: %v", err)}
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+	}
+	if dp.running.stopping.Load() {
+		// Stopped by StopRunning: the exit says nothing about the code,
+		// and the execution container will refuse to be created
+		return &LintResult{ExitCode: -1, Error: ErrWorkerStopping.Error()}
+	}
+
+	output, err := dp.getContainerLogs(containerID, jobID)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to read preprocessor output: %v", jobID, err)
+	}
+	lint := &LintResult{Passed: exitCode == 0, ExitCode: int(exitCode), Output: output.Text}
+	if lint.Passed {
+		log.Printf("🧹 [%s] Preprocessor passed", jobID)
+	} else {
+		log.Printf("🧹 [%s] Preprocessor failed with exit code %d (LINT_GATE=%s)", jobID, exitCode, lintGate)
+	}
+	return lint
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ============================================
+// Pull Admission
+// ============================================
+// When a burst of jobs arrives for a language whose image isn't on the
+// host yet, every worker would start its own pull and compete for
+// bandwidth until all of them hit PULL_TIMEOUT. Instead, the first
+// worker to need the image takes a Redis lock for it (per Docker daemon,
+// so each host pulls once) and pulls; the others wait behind it,
+// checking every PULL_WAIT_POLL (default 500ms) for the image to appear.
+//
+// A job that waits longer than PULL_WAIT_TIMEOUT (default 30s) gives up
+// and is requeued like any other provider failure, freeing its worker
+// while the pull finishes. If the pulling worker fails or dies, its
+// lock is released (or expires after PULL_TIMEOUT) and the next waiter
+// takes over the pull.
+//
+// PULL_WAIT_TIMEOUT=0 disables admission: every job pulls for itself.
+// Without Redis, or if the daemon ID is unknown, jobs also pull directly.
+// ============================================
+
+// ErrPullWaitExceeded means a job gave up waiting for another worker's pull
+var ErrPullWaitExceeded = errors.New("timed out waiting for image pull")
+
+var (
+	pullWaitTimeout = getEnvDuration("PULL_WAIT_TIMEOUT", 30*time.Second)
+	pullWaitPoll    = getEnvDuration("PULL_WAIT_POLL", 500*time.Millisecond)
+)
+
+// releasePullLockScript deletes a pull lock only if this worker still holds it
+var releasePullLockScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then
+  return redis.call("del", KEYS[1])
+end
+return 0`)
+
+// pullAdmission holds the daemon ID the pull locks (and memory
+// reservations, see memory_budget.go) are scoped to
+type pullAdmission struct {
+	once     sync.Once
+	daemonID string
+}
+
+// daemonID returns the Docker daemon's ID, read once ("" if unknown)
+func (dp *DockerProvider) daemonID(ctx context.Context) string {
+	dp.pulls.once.Do(func() {
+		info, err := dp.client.Info(ctx)
+		if err != nil {
+			log.Printf("⚠️  Failed to read Docker daemon ID, pulls and memory won't be coordinated: %v", err)
+			return
+		}
+		dp.pulls.daemonID = info.ID
+	})
+	return dp.pulls.daemonID
+}
+
+// pullLockKey is the lock taken while an image is pulled on a daemon
+func pullLockKey(daemonID, imageName string) string {
+	return fmt.Sprintf("pull-lock:%s:%s", daemonID, imageName)
+}
+
+// admitPull waits until this job may pull imageName. It returns a
+// release function to call once the pull is done, or ready when another
+// worker's pull made the image available in the meantime.
+func (dp *DockerProvider) admitPull(ctx context.Context, imageName string) (release func(), ready bool, err error) {
+	noop := func() {}
+	if pullWaitTimeout <= 0 || redisClient == nil {
+		return noop, false, nil
+	}
+
+	daemonID := dp.daemonID(ctx)
+	if daemonID == "" {
+		return noop, false, nil
+	}
+
+	key := pullLockKey(daemonID, imageName)
+	token := fmt.Sprintf("%s-%d", workerName(), time.Now().UnixNano())
+	waitStart := time.Now()
+	waiting := false
+	for {
+		acquired, err := redisClient.SetNX(ctx, key, token, pullTimeout).Result()
+		if err != nil {
+			log.Printf("⚠️  Failed to take pull lock for %s, pulling directly: %v", imageName, err)
+			return noop, false, nil
+		}
+		if acquired {
+			return func() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+				defer cancel()
+				if err := releasePullLockScript.Run(releaseCtx, redisClient, []string{key}, token).Err(); err != nil {
+					log.Printf("⚠️  Failed to release pull lock for %s: %v", imageName, err)
+				}
+			}, false, nil
+		}
+
+		if !waiting {
+			log.Printf("⏳ Another worker is pulling %s, waiting up to %v", imageName, pullWaitTimeout)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return noop, false, ctx.Err()
+		case <-time.After(pullWaitPoll):
+		}
+
+		if info, _, err := dp.client.ImageInspectWithRaw(ctx, imageName); err == nil &&
+			matchesPlatform(dp.platform, info.Os, info.Architecture, info.Variant) {
+			log.Printf("✅ Image %s pulled by another worker after %v", imageName, time.Since(waitStart).Round(time.Millisecond))
+			return noop, true, nil
+		}
+		if time.Since(waitStart) > pullWaitTimeout {
+			return noop, false, fmt.Errorf("%w: %s after %v", ErrPullWaitExceeded, imageName, pullWaitTimeout)
+		}
+	}
+}
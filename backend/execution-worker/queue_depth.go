@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ============================================
+// Queue Depth Sampling
+// ============================================
+// To autoscale workers on backlog (e.g. an HPA on a Prometheus metric,
+// or KEDA), every QUEUE_DEPTH_INTERVAL (default 15s) the worker reads
+// the length of its Redis lists and exposes it as the gauge
+// rce_queue_depth{queue="..."}:
+//
+//   - the submission queue (Redis backend only; AMQP brokers report
+//     their own depth)
+//   - the dead-letter queue
+//   - <queue>:processing, the jobs held in all workers' processing
+//     lists with QUEUE_ACK_MODE=at-least-once
+//
+// With QUEUE_DEPTH_KEY set (e.g. rce:queue-depth), the same readings are
+// also written to that Redis hash (queue name -> depth), expiring after
+// three intervals so a scaler never acts on stale numbers once every
+// worker is gone.
+//
+// Sampling runs in its own goroutine with its own short timeout and
+// only issues LLEN (plus a SCAN of processing lists), so it never holds
+// up the worker loop. Every worker samples; the readings are the same.
+//
+// QUEUE_DEPTH_INTERVAL=0 disables sampling.
+// ============================================
+
+var (
+	queueDepthInterval = getEnvDuration("QUEUE_DEPTH_INTERVAL", 15*time.Second)
+	queueDepthKey      = getEnv("QUEUE_DEPTH_KEY", "")
+)
+
+var queueDepth = newGauge("rce_queue_depth", "Jobs waiting in each Redis queue, sampled every QUEUE_DEPTH_INTERVAL")
+
+// queueDepthTimeout bounds one sample, so a slow Redis can't pile samples up
+const queueDepthTimeout = 5 * time.Second
+
+// startQueueDepthSampler starts the sampling goroutine. The returned
+// channel is closed once it has stopped (after ctx is cancelled).
+func startQueueDepthSampler(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	if queueDepthInterval <= 0 {
+		close(done)
+		return done
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(queueDepthInterval)
+		defer ticker.Stop()
+		for {
+			sampleQueueDepth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	log.Printf("📏 Queue depth sampler started (every %v)", queueDepthInterval)
+	return done
+}
+
+// sampleQueueDepth reads every queue's length once and publishes it
+func sampleQueueDepth(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, queueDepthTimeout)
+	defer cancel()
+
+	lists := []string{deadLetterQueue}
+	source, isRedis := jobSource.(*redisJobSource)
+	if isRedis {
+		lists = append(lists, source.queue)
+	}
+
+	depths := make(map[string]int64, len(lists)+1)
+	for _, list := range lists {
+		depth, err := redisClient.LLen(ctx, list).Result()
+		if err != nil {
+			log.Printf("⚠️  Failed to read depth of %s: %v", list, err)
+			return
+		}
+		depths[list] = depth
+	}
+	if isRedis && source.processing != "" {
+		depth, err := processingDepth(ctx, source.client, source.queue)
+		if err != nil {
+			log.Printf("⚠️  Failed to read depth of processing lists: %v", err)
+			return
+		}
+		depths[source.queue+":processing"] = depth
+	}
+
+	fields := make(map[string]interface{}, len(depths))
+	for queue, depth := range depths {
+		queueDepth.Set(float64(depth), "queue", queue)
+		fields[queue] = depth
+	}
+
+	if queueDepthKey != "" {
+		pipe := redisClient.TxPipeline()
+		pipe.HSet(ctx, queueDepthKey, fields)
+		pipe.Expire(ctx, queueDepthKey, 3*queueDepthInterval)
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Printf("⚠️  Failed to write queue depth to %s: %v", queueDepthKey, err)
+		}
+	}
+}
+
+// processingDepth sums the lengths of every worker's processing list for queue
+func processingDepth(ctx context.Context, client *redis.Client, queue string) (int64, error) {
+	var total int64
+	iter := client.ScanType(ctx, 0, processingListPrefix(queue)+"*", 100, "list").Iterator()
+	for iter.Next(ctx) {
+		depth, err := client.LLen(ctx, iter.Val()).Result()
+		if err != nil {
+			return 0, err
+		}
+		total += depth
+	}
+	return total, iter.Err()
+}
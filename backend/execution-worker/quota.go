@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// ============================================
+// Daily Execution Quota
+// ============================================
+// A hard cap on executions per UTC day across all workers, for cost
+// control. Every execution increments a shared Redis counter
+// (rce:executions:<YYYY-MM-DD>); once it passes DAILY_EXECUTION_QUOTA,
+// jobs are marked "quota_exceeded" without running until the next day's
+// counter starts at zero.
+//
+// DAILY_EXECUTION_QUOTA=0 (default) disables the quota. Results served
+// from the result cache don't count, and a job retried after a transient
+// failure counts once. If Redis can't be reached the
+// quota fails open, so an outage doesn't stop all executions.
+// ============================================
+
+const (
+	quotaKeyPrefix = "rce:executions:"
+	quotaKeyTTL    = 48 * time.Hour // Outlives the day the key counts, then expires
+)
+
+var dailyExecutionQuota = int64(getEnvInt("DAILY_EXECUTION_QUOTA", 0))
+
+// quotaState remembers which day this worker last saw the quota exhausted,
+// so reaching and resetting are each logged once
+var quotaState struct {
+	mu            sync.Mutex
+	exhaustedDate string
+}
+
+// quotaKey returns the counter key for the UTC day containing now
+func quotaKey(now time.Time) string {
+	return quotaKeyPrefix + now.UTC().Format("2006-01-02")
+}
+
+// reserveExecution counts one execution against today's quota and
+// reports whether it is allowed. A requeued job was counted when it
+// first ran, so retries pass without counting again.
+func reserveExecution(ctx context.Context, job Job) bool {
+	if dailyExecutionQuota <= 0 || job.RetryCount > 0 {
+		return true
+	}
+	jobID := job.JobID
+
+	now := time.Now()
+	key := quotaKey(now)
+	date := now.UTC().Format("2006-01-02")
+
+	pipe := redisClient.TxPipeline()
+	count := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, quotaKeyTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("⚠️  [%s] Quota check failed, allowing execution: %v", jobID, err)
+		return true
+	}
+
+	quotaState.mu.Lock()
+	defer quotaState.mu.Unlock()
+
+	if count.Val() > dailyExecutionQuota {
+		if quotaState.exhaustedDate != date {
+			log.Printf("🚫 Daily execution quota of %d reached for %s; rejecting jobs until the next UTC day", dailyExecutionQuota, date)
+			quotaState.exhaustedDate = date
+		}
+		return false
+	}
+
+	if quotaState.exhaustedDate != "" && quotaState.exhaustedDate != date {
+		log.Printf("✅ Daily execution quota reset for %s (%d executions allowed)", date, dailyExecutionQuota)
+		quotaState.exhaustedDate = ""
+	}
+	return true
+}
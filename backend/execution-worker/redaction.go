@@ -0,0 +1,52 @@
+package main
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// ============================================
+// Submission Redaction
+// ============================================
+// Some deployments must not keep user source code or input, only the
+// output (data minimization). The gateway stores the whole submission,
+// so when a job finishes the worker removes the redacted fields from
+// its document:
+//   PERSIST_CODE=false  - removes code and files; the analysis
+//                         notification carries no code either
+//   PERSIST_STDIN=false - removes stdin and stdinParts
+// Both default to true. Dead-lettered jobs are redacted the same way,
+// and unparsed dead-lettered payloads keep no preview.
+//
+// Analysis needs the code, so with PERSIST_CODE=false the analysis
+// worker receives nothing to analyze.
+// ============================================
+
+var (
+	persistCode  = getEnvBool("PERSIST_CODE", true)
+	persistStdin = getEnvBool("PERSIST_STDIN", true)
+)
+
+// redactedFields returns the submission fields to remove from a finished job's document
+func redactedFields() bson.M {
+	fields := bson.M{}
+	if !persistCode {
+		fields["code"] = ""
+		fields["files"] = ""
+	}
+	if !persistStdin {
+		fields["stdin"] = ""
+		fields["stdinParts"] = ""
+	}
+	return fields
+}
+
+// redactJob clears the fields that must not be persisted from a copy of job
+func redactJob(job Job) Job {
+	if !persistCode {
+		job.Code = ""
+		job.Files = nil
+	}
+	if !persistStdin {
+		job.Stdin = ""
+		job.StdinParts = nil
+	}
+	return job
+}
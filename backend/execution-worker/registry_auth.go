@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/errdefs"
+)
+
+// ============================================
+// Registry Authentication
+// ============================================
+// Language images may live in private registries. Credentials are
+// looked up by the registry host of the image being pulled, from:
+//   - REGISTRY_AUTH_CONFIG: path to a Docker config.json; every entry
+//     under "auths" is loaded (credential helpers are not supported)
+//   - REGISTRY_USERNAME / REGISTRY_PASSWORD for REGISTRY_SERVER
+//     (default docker.io); these override the config file for that host
+//
+// Images with no registry host (python:3.9-alpine) are on docker.io.
+// If a registry rejects the configured credentials, the job fails with
+// a configuration_error naming the registry.
+// ============================================
+
+const dockerHubHost = "docker.io"
+
+// ErrRegistryAuth means a registry rejected the credentials configured for it
+var ErrRegistryAuth = errors.New("registry rejected credentials")
+
+// registryCredentials maps a registry host to its credentials
+var registryCredentials = map[string]registry.AuthConfig{}
+
+// dockerConfigFile is the subset of ~/.docker/config.json we read
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+}
+
+// loadRegistryAuth loads registry credentials from the environment.
+// Must be called before the worker loop starts.
+func loadRegistryAuth() error {
+	if path := getEnv("REGISTRY_AUTH_CONFIG", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("REGISTRY_AUTH_CONFIG: %w", err)
+		}
+
+		var config dockerConfigFile
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("REGISTRY_AUTH_CONFIG %s: %w", path, err)
+		}
+
+		for server, entry := range config.Auths {
+			auth := registry.AuthConfig{
+				Username:      entry.Username,
+				Password:      entry.Password,
+				IdentityToken: entry.IdentityToken,
+			}
+			if entry.Auth != "" {
+				decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+				if err != nil {
+					return fmt.Errorf("REGISTRY_AUTH_CONFIG %s: invalid auth for %s: %w", path, server, err)
+				}
+				username, password, ok := strings.Cut(string(decoded), ":")
+				if !ok {
+					return fmt.Errorf("REGISTRY_AUTH_CONFIG %s: invalid auth for %s", path, server)
+				}
+				auth.Username, auth.Password = username, password
+			}
+			host := normalizeRegistryHost(server)
+			auth.ServerAddress = host
+			registryCredentials[host] = auth
+		}
+	}
+
+	username := getEnv("REGISTRY_USERNAME", "")
+	password := getEnv("REGISTRY_PASSWORD", "")
+	if username != "" || password != "" {
+		if username == "" || password == "" {
+			return fmt.Errorf("REGISTRY_USERNAME and REGISTRY_PASSWORD must be set together")
+		}
+		host := normalizeRegistryHost(getEnv("REGISTRY_SERVER", dockerHubHost))
+		registryCredentials[host] = registry.AuthConfig{Username: username, Password: password, ServerAddress: host}
+	}
+
+	for host := range registryCredentials {
+		log.Printf("🔑 Registry credentials loaded for %s", host)
+	}
+	return nil
+}
+
+// normalizeRegistryHost reduces a config.json server key
+// ("https://index.docker.io/v1/") to a bare host
+func normalizeRegistryHost(server string) string {
+	host := server
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), "/")
+
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return dockerHubHost
+	}
+	return host
+}
+
+// registryHost returns the registry an image reference is pulled from
+func registryHost(ref string) string {
+	first, _, found := strings.Cut(ref, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return normalizeRegistryHost(first)
+	}
+	return dockerHubHost
+}
+
+// registryAuthFor returns the encoded RegistryAuth header for pulling ref,
+// or "" when no credentials are configured for its registry
+func registryAuthFor(ref string) (string, error) {
+	auth, ok := registryCredentials[registryHost(ref)]
+	if !ok {
+		return "", nil
+	}
+	return registry.EncodeAuthConfig(auth)
+}
+
+// isRegistryAuthError reports whether a pull error is the registry
+// refusing our credentials
+func isRegistryAuthError(err error) bool {
+	if errdefs.IsUnauthorized(err) || errdefs.IsForbidden(err) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unauthorized") ||
+		strings.Contains(msg, "authentication required") ||
+		strings.Contains(msg, "access denied") ||
+		strings.Contains(msg, "denied:")
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ============================================
+// Reliable Redis Queue
+// ============================================
+// With QUEUE_ACK_MODE=at-most-once (default), BLPOP removes a job from
+// the Redis queue as it is taken: if the worker dies mid-job, the job is
+// gone from Redis (only the stuck job reaper can recover it, from
+// MongoDB).
+//
+// With QUEUE_ACK_MODE=at-least-once, BLMOVE moves each job into this
+// worker's own processing list, <queue>:processing:<worker>, and the ack
+// removes it once processJob returns. While running, the worker refreshes
+// a heartbeat key, <queue>:worker:<worker>. The stuck job reaper (see
+// job_reaper.go, so it must be enabled) moves jobs from any processing
+// list whose worker has no heartbeat back to the front of the queue.
+//
+// A worker's name is its hostname and PID, which in a container is the
+// same after a restart (PID 1, same hostname), so a restarted worker
+// owns the list its previous run left behind. Before its first
+// heartbeat, the worker moves whatever is in its own processing list
+// back to the front of the queue; otherwise the heartbeat would keep
+// those jobs hidden from the reaper for good.
+//
+// A job recovered this way may already have partly run, so it can run
+// twice. Combine with STUCK_JOB_ACTION=fail, so the reaper doesn't also
+// requeue it from MongoDB.
+// ============================================
+
+var queueAckMode = getEnv("QUEUE_ACK_MODE", "at-most-once")
+
+const (
+	processingHeartbeatTTL      = 30 * time.Second
+	processingHeartbeatInterval = 10 * time.Second
+)
+
+// validateQueueAckMode checks QUEUE_ACK_MODE
+func validateQueueAckMode() error {
+	if queueAckMode != "at-most-once" && queueAckMode != "at-least-once" {
+		return fmt.Errorf("QUEUE_ACK_MODE must be at-most-once or at-least-once, got %q", queueAckMode)
+	}
+	return nil
+}
+
+// processingListPrefix is the key prefix of every worker's processing list for queue
+func processingListPrefix(queue string) string {
+	return queue + ":processing:"
+}
+
+// heartbeatKey is the key that shows a worker is alive
+func heartbeatKey(queue, worker string) string {
+	return queue + ":worker:" + worker
+}
+
+// workerName identifies this worker process in shared Redis keys
+func workerName() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// newReliableRedisJobSource creates an at-least-once source and starts its heartbeat
+func newReliableRedisJobSource(client *redis.Client, queue string) *redisJobSource {
+	worker := workerName()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &redisJobSource{
+		client:          client,
+		queue:           queue,
+		processing:      processingListPrefix(queue) + worker,
+		worker:          worker,
+		cancelHeartbeat: cancel,
+	}
+
+	s.recoverOwnJobs()
+	s.heartbeat(ctx)
+	go func() {
+		ticker := time.NewTicker(processingHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.heartbeat(ctx)
+			}
+		}
+	}()
+	return s
+}
+
+// recoverOwnJobs requeues jobs left in this worker's processing list by a
+// previous run under the same name. Must be called before the first heartbeat.
+func (s *redisJobSource) recoverOwnJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	recovered, err := requeueProcessingList(ctx, s.client, s.processing, s.queue)
+	if err != nil {
+		log.Printf("⚠️  Failed to recover jobs from %s: %v", s.processing, err)
+	}
+	if recovered > 0 {
+		log.Printf("🪦 Requeued %d job(s) left by a previous run of worker %s", recovered, s.worker)
+	}
+}
+
+// heartbeat marks this worker's processing list as owned
+func (s *redisJobSource) heartbeat(ctx context.Context) {
+	if err := s.client.Set(ctx, heartbeatKey(s.queue, s.worker), time.Now().UTC().Format(time.RFC3339), processingHeartbeatTTL).Err(); err != nil && ctx.Err() == nil {
+		log.Printf("⚠️  Failed to refresh queue heartbeat: %v", err)
+	}
+}
+
+// nextReliable moves the next job into the processing list; ack removes it
+func (s *redisJobSource) nextReliable(ctx context.Context) (string, func(), error) {
+	payload, err := s.client.BLMove(ctx, s.queue, s.processing, "LEFT", "RIGHT", 0).Result()
+	if err != nil {
+		return "", nil, err
+	}
+
+	ack := func() {
+		ackCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.client.LRem(ackCtx, s.processing, 1, payload).Err(); err != nil {
+			log.Printf("⚠️  Failed to ack job (it may be redelivered): %v", err)
+		}
+	}
+	return payload, ack, nil
+}
+
+// recoverAbandonedJobs moves jobs from processing lists whose worker
+// stopped heartbeating back to the front of the queue
+func recoverAbandonedJobs(ctx context.Context, client *redis.Client, queue string) {
+	prefix := processingListPrefix(queue)
+	iter := client.ScanType(ctx, 0, prefix+"*", 100, "list").Iterator()
+	for iter.Next(ctx) {
+		list := iter.Val()
+		worker := strings.TrimPrefix(list, prefix)
+
+		alive, err := client.Exists(ctx, heartbeatKey(queue, worker)).Result()
+		if err != nil {
+			log.Printf("⚠️  Failed to check worker %s heartbeat: %v", worker, err)
+			continue
+		}
+		if alive > 0 {
+			continue
+		}
+
+		recovered, err := requeueProcessingList(ctx, client, list, queue)
+		if err != nil {
+			log.Printf("⚠️  Failed to recover jobs from %s: %v", list, err)
+		}
+		if recovered > 0 {
+			log.Printf("🪦 Requeued %d job(s) abandoned by worker %s", recovered, worker)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		log.Printf("⚠️  Processing list scan failed: %v", err)
+	}
+}
+
+// requeueProcessingList moves every job in a processing list back to the
+// front of the queue and returns how many it moved
+func requeueProcessingList(ctx context.Context, client *redis.Client, list, queue string) (int, error) {
+	recovered := 0
+	for {
+		// Oldest last, so pushing each to the front keeps their order
+		err := client.LMove(ctx, list, queue, "RIGHT", "LEFT").Err()
+		if errors.Is(err, redis.Nil) {
+			return recovered, nil
+		}
+		if err != nil {
+			return recovered, err
+		}
+		recovered++
+	}
+}
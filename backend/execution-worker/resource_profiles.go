@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ============================================
+// Resource Profiles
+// ============================================
+// Named bundles of memory, CPU, process and time limits, selected per
+// job with Job.Profile (default "small"). This suits tiered plans better
+// than individual overrides.
+//
+// Built-in profiles can be extended or overridden at startup with
+// RESOURCE_PROFILES, a JSON object keyed by profile name:
+//
+//	RESOURCE_PROFILES='{"xl":{"memoryMb":1024,"cpuQuota":200000,"pidsLimit":200,"timeoutMs":30000,"cpuTimeLimitSec":20}}'
+//
+// "memorySwapRatio" optionally allows swap on top of memoryMb (see
+// memory_swap.go).
+//
+// The worker trusts the queue: the API gateway is responsible for only
+// letting trusted callers select the larger profiles.
+// ============================================
+
+// DefaultProfile is used when a job doesn't name a profile
+const DefaultProfile = "small"
+
+// ResourceProfile bundles the resource limits applied to an execution container
+type ResourceProfile struct {
+	Name            string
+	MemoryBytes     int64
+	CPUQuota        int64         // Relative to CPUPeriod (100000 = 1 CPU)
+	PidsLimit       int64         // Max processes/threads
+	Timeout         time.Duration // Wall-clock limit; 0 = use the language's timeout
+	CPUTimeLimitSec int64         // RLIMIT_CPU
+	MemorySwapRatio float64       // MemorySwap = MemoryBytes * ratio; 0 = no swap (see memory_swap.go)
+}
+
+// Limits are the effective limits an execution ran with, echoed in its
+// result so it's clear what constraints applied after profiles and
+// any language or job overrides
+type Limits struct {
+	Profile         string `json:"profile" bson:"profile"`
+	MemoryBytes     int64  `json:"memoryBytes" bson:"memoryBytes"`
+	MemorySwapBytes int64  `json:"memorySwapBytes" bson:"memorySwapBytes"` // Memory plus swap; equal to memoryBytes without swap
+	CPUQuota        int64  `json:"cpuQuota" bson:"cpuQuota"`               // Relative to cpuPeriod
+	CPUPeriod       int64  `json:"cpuPeriod" bson:"cpuPeriod"`             // Microseconds
+	PidsLimit       int64  `json:"pidsLimit" bson:"pidsLimit"`
+	CPUTimeLimitSec int64  `json:"cpuTimeLimitSec" bson:"cpuTimeLimitSec"`
+	TimeoutMs       int64  `json:"timeoutMs" bson:"timeoutMs"`                       // Wall-clock, including any compile and setup/teardown time
+	CpusetCpus      string `json:"cpusetCpus,omitempty" bson:"cpusetCpus,omitempty"` // Cores the container was pinned to (see cpuset.go)
+}
+
+// appliedLimits records the limits from a container's resources and its timeout
+func appliedLimits(profileName string, resources container.Resources, timeout time.Duration) *Limits {
+	limits := &Limits{
+		Profile:         profileName,
+		MemoryBytes:     resources.Memory,
+		MemorySwapBytes: resources.MemorySwap,
+		CPUQuota:        resources.CPUQuota,
+		CPUPeriod:       resources.CPUPeriod,
+		TimeoutMs:       timeout.Milliseconds(),
+		CpusetCpus:      resources.CpusetCpus,
+	}
+	if resources.PidsLimit != nil {
+		limits.PidsLimit = *resources.PidsLimit
+	}
+	for _, ulimit := range resources.Ulimits {
+		if ulimit.Name == "cpu" {
+			limits.CPUTimeLimitSec = ulimit.Soft
+		}
+	}
+	return limits
+}
+
+// profileConfig is the RESOURCE_PROFILES JSON form of a ResourceProfile
+type profileConfig struct {
+	MemoryMB        int64   `json:"memoryMb"`
+	CPUQuota        int64   `json:"cpuQuota"`
+	PidsLimit       int64   `json:"pidsLimit"`
+	TimeoutMs       int64   `json:"timeoutMs"`
+	CPUTimeLimitSec int64   `json:"cpuTimeLimitSec"`
+	MemorySwapRatio float64 `json:"memorySwapRatio"`
+}
+
+// resourceProfiles is the profile registry, populated by loadResourceProfiles
+var resourceProfiles = map[string]ResourceProfile{
+	"small": {
+		MemoryBytes:     MemoryLimit,
+		CPUQuota:        CPUQuota,
+		PidsLimit:       50,
+		CPUTimeLimitSec: CPUTimeLimitSec,
+	},
+	"medium": {
+		MemoryBytes:     256 * 1024 * 1024,
+		CPUQuota:        100000,
+		PidsLimit:       100,
+		Timeout:         10 * time.Second,
+		CPUTimeLimitSec: 8,
+	},
+	"large": {
+		MemoryBytes:     512 * 1024 * 1024,
+		CPUQuota:        200000,
+		PidsLimit:       200,
+		Timeout:         20 * time.Second,
+		CPUTimeLimitSec: 30,
+	},
+}
+
+// loadResourceProfiles merges RESOURCE_PROFILES into the built-in registry and validates it.
+// Must be called before the worker loop starts.
+func loadResourceProfiles() error {
+	if raw := getEnv("RESOURCE_PROFILES", ""); raw != "" {
+		var custom map[string]profileConfig
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			return fmt.Errorf("invalid RESOURCE_PROFILES: %w", err)
+		}
+		for name, cfg := range custom {
+			resourceProfiles[name] = ResourceProfile{
+				MemoryBytes:     cfg.MemoryMB * 1024 * 1024,
+				CPUQuota:        cfg.CPUQuota,
+				PidsLimit:       cfg.PidsLimit,
+				Timeout:         time.Duration(cfg.TimeoutMs) * time.Millisecond,
+				CPUTimeLimitSec: cfg.CPUTimeLimitSec,
+				MemorySwapRatio: cfg.MemorySwapRatio,
+			}
+		}
+	}
+
+	for name, profile := range resourceProfiles {
+		if profile.MemoryBytes <= 0 || profile.CPUQuota <= 0 || profile.PidsLimit <= 0 || profile.CPUTimeLimitSec <= 0 {
+			return fmt.Errorf("resource profile %q must set positive memory, CPU quota, pids and CPU time limits", name)
+		}
+		if err := validateSwapRatio(profile.MemorySwapRatio); err != nil {
+			return fmt.Errorf("resource profile %q: %w", name, err)
+		}
+		profile.Name = name
+		resourceProfiles[name] = profile
+	}
+
+	if _, ok := resourceProfiles[DefaultProfile]; !ok {
+		return fmt.Errorf("default resource profile %q is not defined", DefaultProfile)
+	}
+
+	names := make([]string, 0, len(resourceProfiles))
+	for name := range resourceProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	log.Printf("📐 Resource profiles: %v", names)
+
+	return nil
+}
+
+// resolveProfile returns the named profile, or the default when name is empty
+func resolveProfile(name string) (ResourceProfile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	profile, ok := resourceProfiles[name]
+	if !ok {
+		return ResourceProfile{}, fmt.Errorf("unknown resource profile: %s", name)
+	}
+	return profile, nil
+}
+
+// timeoutFor returns the wall-clock timeout for a language under this profile
+func (p ResourceProfile) timeoutFor(langConfig LanguageConfig) time.Duration {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return langConfig.Timeout
+}
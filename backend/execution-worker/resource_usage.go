@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ============================================
+// Resource Usage
+// ============================================
+// Reports how much CPU a program used, alongside wall-clock time.
+//
+// A container's cgroup is gone by the time it has exited, so usage can't
+// be read afterwards. Instead the Docker stats stream is followed while
+// the program runs and the last cpu_stats sample is kept. The daemon
+// samples roughly once a second, so a program that exits before the
+// first sample reports no CPU time (CPUTimeMs 0).
+//
+// NOTE: Docker's stats API doesn't expose context switches, so they are
+// not reported.
+// ============================================
+
+// usageGracePeriod is how long Stop waits for a final sample after exit
+const usageGracePeriod = 200 * time.Millisecond
+
+// usageSampleInterval is how often the daemon samples, so how far the
+// last sample can lag the CPU time a program actually used
+const usageSampleInterval = time.Second
+
+// cpuLimitReached reports whether a program's sampled CPU time shows it
+// could have run into the profile's RLIMIT_CPU
+func cpuLimitReached(profile ResourceProfile, cpuTime time.Duration) bool {
+	limit := time.Duration(profile.CPUTimeLimitSec) * time.Second
+	return cpuTime > 0 && cpuTime >= limit-usageSampleInterval
+}
+
+// usageSampler follows a container's stats stream in the background
+type usageSampler struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	cpuTime time.Duration // Only read after done is closed
+}
+
+// sampleUsage starts following a running container's resource usage
+func (dp *DockerProvider) sampleUsage(containerID string) *usageSampler {
+	ctx, cancel := context.WithCancel(context.Background())
+	sampler := &usageSampler{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(sampler.done)
+
+		stats, err := dp.client.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			return // Usage is best-effort
+		}
+		defer stats.Body.Close()
+
+		decoder := json.NewDecoder(stats.Body)
+		for {
+			var sample container.StatsResponse
+			if err := decoder.Decode(&sample); err != nil {
+				return
+			}
+			// Samples taken after exit are zeroed; keep the last real one
+			if usage := sample.CPUStats.CPUUsage.TotalUsage; usage > 0 {
+				sampler.cpuTime = time.Duration(usage) // Nanoseconds on Linux
+			}
+		}
+	}()
+
+	return sampler
+}
+
+// Stop ends sampling and returns the CPU time used (0 if unavailable)
+func (s *usageSampler) Stop() time.Duration {
+	select {
+	case <-s.done:
+	case <-time.After(usageGracePeriod):
+	}
+	s.cancel()
+	<-s.done
+	return s.cpuTime
+}
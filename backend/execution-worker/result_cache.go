@@ -0,0 +1,201 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ============================================
+// Result Cache
+// ============================================
+// When a class runs the same sample program over and over, every
+// submission would otherwise cost a container. With
+// ENABLE_RESULT_CACHE=true, completed results are kept in a bounded
+// in-memory LRU keyed by a hash of everything that can affect the
+// program's output (including its stdin); an identical submission is
+// answered from the cache.
+//
+// Config:
+//   RESULT_CACHE_SIZE - max cached results (default 1000)
+//   RESULT_CACHE_TTL  - how long a result stays valid (default 10m)
+//
+// Only "completed" results are cached: failures may be infrastructure
+// errors, and timeouts depend on load. Jobs that collect artifacts are
+// never cached, as their files are stored per job.
+//
+// A cached result has no executionId of its own: cachedFrom names the
+// run it was copied from, and its imageDigest, phaseTimings, limits and
+// timings describe that run.
+//
+// NOTE: the cache is per worker process and lost on restart.
+// ============================================
+
+var (
+	enableResultCache = getEnvBool("ENABLE_RESULT_CACHE", false)
+	resultCacheSize   = getEnvInt("RESULT_CACHE_SIZE", 1000)
+	resultCacheTTL    = getEnvDuration("RESULT_CACHE_TTL", 10*time.Minute)
+
+	results = newResultCache(resultCacheSize, resultCacheTTL)
+
+	resultCacheLookups = newCounter("rce_result_cache_lookups_total", "Result cache lookups by outcome (hit or miss)")
+)
+
+// resultCacheInput is everything about a job that can change its output.
+// Any new Job field that affects execution must be added here.
+type resultCacheInput struct {
+	Language   string            `json:"language"`
+	Code       string            `json:"code"`
+	Files      map[string]string `json:"files,omitempty"`
+	EntryPoint string            `json:"entryPoint,omitempty"`
+	Profile    string            `json:"profile,omitempty"`
+	Variant    string            `json:"variant,omitempty"`
+	Seed       *int64            `json:"seed,omitempty"`
+	Stdin      string            `json:"stdin,omitempty"`
+
+	UseHarness     bool   `json:"useHarness,omitempty"`
+	Mode           string `json:"mode,omitempty"`
+	StopOnOutput   bool   `json:"stopOnOutput,omitempty"`
+	OutputEncoding string `json:"outputEncoding,omitempty"`
+	SetupScript    string `json:"setupScript,omitempty"`
+	TeardownScript string `json:"teardownScript,omitempty"`
+}
+
+// resultCacheKey hashes a job's execution inputs. ok is false for jobs
+// that must not be cached.
+func resultCacheKey(job Job) (key string, ok bool) {
+	if !enableResultCache || len(job.ArtifactPaths) > 0 {
+		return "", false
+	}
+
+	// encoding/json sorts map keys, so equal inputs hash equally
+	data, err := json.Marshal(resultCacheInput{
+		Language:   job.Language,
+		Code:       job.Code,
+		Files:      job.Files,
+		EntryPoint: job.EntryPoint,
+		Profile:    job.Profile,
+		Variant:    variantLabel(job),
+		Seed:       job.Seed,
+		Stdin:      job.Stdin,
+
+		UseHarness:     job.UseHarness,
+		Mode:           job.Mode,
+		StopOnOutput:   job.StopOnOutput,
+		OutputEncoding: job.OutputEncoding,
+		SetupScript:    job.SetupScript,
+		TeardownScript: job.TeardownScript,
+	})
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// resultCacheEntry is a cached result and when it was stored
+type resultCacheEntry struct {
+	key      string
+	result   ExecutionResult
+	storedAt time.Time
+}
+
+// resultCache is a size- and age-bounded LRU of execution results
+type resultCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // Front is most recently used
+	entries  map[string]*list.Element
+}
+
+func newResultCache(capacity int, ttl time.Duration) *resultCache {
+	return &resultCache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns a copy of the cached result for key, if present and fresh
+func (c *resultCache) Get(key string) (*ExecutionResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*resultCacheEntry)
+	if time.Since(entry.storedAt) > c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	result := entry.result
+	return &result, true
+}
+
+// Put stores a copy of result under key, evicting the least recently used entry when full
+func (c *resultCache) Put(key string, result *ExecutionResult) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &resultCacheEntry{key: key, result: *result, storedAt: time.Now()}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultCacheEntry).key)
+	}
+}
+
+// cachedResult returns the cached result for an identical earlier job, if any
+func cachedResult(job Job) *ExecutionResult {
+	key, ok := resultCacheKey(job)
+	if !ok {
+		return nil
+	}
+
+	result, hit := results.Get(key)
+	if !hit {
+		resultCacheLookups.Inc("outcome", "miss")
+		return nil
+	}
+	resultCacheLookups.Inc("outcome", "hit")
+	result.Cached = true
+	result.ColdStart = false
+	// The image digest, timings and limits stay those of the run that
+	// produced the result; it is recorded as cachedFrom, not as this job's run
+	result.CachedFrom = result.ExecutionID
+	result.ExecutionID = ""
+	return result
+}
+
+// cacheResult stores a job's result for identical future jobs
+func cacheResult(job Job, result *ExecutionResult) {
+	if result.Status != "completed" {
+		return
+	}
+	if key, ok := resultCacheKey(job); ok {
+		results.Put(key, result)
+	}
+}
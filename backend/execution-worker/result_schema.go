@@ -0,0 +1,56 @@
+package main
+
+// ============================================
+// Result Schema Version
+// ============================================
+// Every stored and published result carries "schemaVersion", so
+// consumers can branch on the result's shape as fields are added. Bump
+// resultSchemaVersion whenever a field is added, removed or changes
+// meaning, and record what changed below.
+//
+// Versions:
+//
+//   1 - First versioned shape. Always present: output, executionTime
+//       (ms), exitCode, schemaVersion. When applicable: error, signal,
+//       encoding, rawOutput, truncated, truncation {truncated, reason,
+//       originalBytes}, coldStart, cpuTimeMs, stdinConsumed, cached,
+//       artifacts, queueTimeMs. Published results and webhooks also
+//       carry jobId, status and metadata.
+//   2 - Adds imageDigest (when known): the repo digest or ID of the
+//       image that ran the code.
+//   3 - Adds outputBytesTotal (when non-zero): bytes the program wrote
+//       to stdout and stderr, including any discarded past the limit.
+//   4 - Adds phaseTimings (when the execution ran): ms spent in each of
+//       image, create, start and wait.
+//   5 - Adds stderrOnly (when true): the program wrote only to stderr.
+//   6 - Adds returnValue (eval mode, when the code ended with an
+//       expression): the expression's value as the language prints it.
+//   7 - Adds limits (when a container was created): the effective
+//       {profile, memoryBytes, cpuQuota, cpuPeriod, pidsLimit,
+//       cpuTimeLimitSec, timeoutMs, cpusetCpus} it ran with.
+//   8 - rawOutput is also set when output filters removed lines; it
+//       then holds the unfiltered output.
+//   9 - Adds limits.memorySwapBytes: the memory plus swap limit, equal
+//       to memoryBytes unless a swap ratio allowed swap.
+//  10 - Adds executionId (when the result came from a run): the run
+//       that produced it, distinct from jobId across retries. Stored
+//       submissions also get an events array with one entry per run.
+//  11 - Adds variant: the A/B variant the job ran under ("control"
+//       unless variants are configured).
+//  12 - Adds workerVersion and configHash to stored results: the
+//       worker build and effective configuration that produced them.
+//  13 - Adds lint (languages with a preprocessor): {passed, exitCode,
+//       output, blocked, error}, and the status "lint_failed".
+//  14 - With encoding "base64", output is the program's stdout bytes
+//       alone, before any processing; stderr (when non-empty) holds
+//       its stderr as text, and rawOutput is never set.
+//  15 - Cached results no longer carry executionId; cachedFrom holds
+//       the run they were copied from, which their imageDigest,
+//       phaseTimings, limits, cpuTimeMs and executionTime describe.
+//
+// Results stored before versioning have no schemaVersion; treat them as
+// version 0 (a subset of version 1).
+// ============================================
+
+// resultSchemaVersion is the shape of results written by this worker
+const resultSchemaVersion = 15
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================
+// Sandbox Diagnostic
+// ============================================
+// The self-test (see selftest.go) proves a language can print; it
+// doesn't show whether the hardening (dropped capabilities, pids limit,
+// memory limit, no-new-privileges) still lets the runtime do ordinary
+// things after an image upgrade.
+//
+// With RUN_SANDBOX_DIAGNOSTIC=true, every language with a DiagnosticCode
+// runs it under the full sandbox at startup. The probe program tries
+// each operation in sandboxProbes and prints one line per probe:
+//
+//   probe:<name>:ok
+//   probe:<name>:fail:<reason>
+//
+// The result is logged as a per-language capability report. Failures
+// are reported but don't stop the worker: a probe failing may be the
+// intended effect of a hardening change.
+// ============================================
+
+var runSandboxDiagnostic = getEnvBool("RUN_SANDBOX_DIAGNOSTIC", false)
+
+// sandboxProbes are the operations every probe program tries, in report order
+var sandboxProbes = []string{"tmp_write", "thread", "memory_32mb"}
+
+const probePrefix = "probe:"
+
+const pythonSandboxProbe = `import os
+import threading
+
+
+def probe(name, fn):
+    try:
+        fn()
+        print(f"probe:{name}:ok")
+    except Exception as e:
+        print(f"probe:{name}:fail:{e}")
+
+
+def tmp_write():
+    with open("/tmp/rce-probe", "w") as f:
+        f.write("x")
+    os.remove("/tmp/rce-probe")
+
+
+def thread():
+    t = threading.Thread(target=lambda: None)
+    t.start()
+    t.join()
+
+
+def memory():
+    bytearray(32 * 1024 * 1024)
+
+
+probe("tmp_write", tmp_write)
+probe("thread", thread)
+probe("memory_32mb", memory)
+`
+
+const javascriptSandboxProbe = `const fs = require("fs");
+const { Worker } = require("worker_threads");
+
+function probe(name, fn) {
+  try {
+    fn();
+    console.log(` + "`probe:${name}:ok`" + `);
+  } catch (e) {
+    console.log(` + "`probe:${name}:fail:${e.message}`" + `);
+  }
+}
+
+probe("tmp_write", () => {
+  fs.writeFileSync("/tmp/rce-probe", "x");
+  fs.unlinkSync("/tmp/rce-probe");
+});
+probe("memory_32mb", () => Buffer.alloc(32 * 1024 * 1024, 1));
+
+try {
+  new Worker("require('worker_threads').parentPort.postMessage(1)", { eval: true })
+    .on("message", () => console.log("probe:thread:ok"))
+    .on("error", (e) => console.log(` + "`probe:thread:fail:${e.message}`" + `));
+} catch (e) {
+  console.log(` + "`probe:thread:fail:${e.message}`" + `);
+}
+`
+
+const rustSandboxProbe = `use std::fs;
+use std::thread;
+
+fn report(name: &str, result: Result<(), String>) {
+    match result {
+        Ok(()) => println!("probe:{}:ok", name),
+        Err(e) => println!("probe:{}:fail:{}", name, e),
+    }
+}
+
+fn main() {
+    report(
+        "tmp_write",
+        fs::write("/tmp/rce-probe", "x")
+            .and_then(|_| fs::remove_file("/tmp/rce-probe"))
+            .map_err(|e| e.to_string()),
+    );
+    report(
+        "thread",
+        thread::Builder::new()
+            .spawn(|| {})
+            .map_err(|e| e.to_string())
+            .and_then(|h| h.join().map_err(|_| "thread panicked".to_string())),
+    );
+    let memory = vec![1u8; 32 * 1024 * 1024];
+    report("memory_32mb", if memory.len() == 32 * 1024 * 1024 { Ok(()) } else { Err("short allocation".to_string()) });
+}
+`
+
+// bashSandboxProbe has no threads; a background subshell stands in for one
+const bashSandboxProbe = `probe() {
+  name=$1
+  shift
+  if err=$("$@" 2>&1); then
+    echo "probe:$name:ok"
+  else
+    echo "probe:$name:fail:$err"
+  fi
+}
+
+tmp_write() { echo x >/tmp/rce-probe && rm /tmp/rce-probe; }
+thread() { ( : ) & wait $!; }
+memory() {
+  local m
+  m=$(head -c 33554432 /dev/zero | tr '\0' x)
+  [ ${#m} -eq 33554432 ] || { echo "short allocation"; return 1; }
+}
+
+probe tmp_write tmp_write
+probe thread thread
+probe memory_32mb memory
+`
+
+// diagnoseSandbox runs the language's probe program and returns each probe's outcome
+func diagnoseSandbox(ctx context.Context, language string, langConfig LanguageConfig) (map[string]string, error) {
+	job := Job{
+		JobID:       fmt.Sprintf("diagnostic-%s-%d", language, time.Now().UnixNano()),
+		Language:    language,
+		Code:        langConfig.DiagnosticCode,
+		SubmittedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	result, err := executionProvider.ExecuteCode(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes := make(map[string]string)
+	for _, line := range strings.Split(result.Output, "\n") {
+		rest, ok := strings.CutPrefix(strings.TrimSpace(line), probePrefix)
+		if !ok {
+			continue
+		}
+		if name, outcome, ok := strings.Cut(rest, ":"); ok {
+			outcomes[name] = outcome
+		}
+	}
+	if len(outcomes) == 0 {
+		return nil, fmt.Errorf("no probe results (status %s, exit code %d): %s", result.Status, result.ExitCode, truncate(result.Output, 200))
+	}
+	return outcomes, nil
+}
+
+// runSandboxDiagnostics logs a capability report for every language with a probe program
+func runSandboxDiagnostics(ctx context.Context) {
+	languages := GetSupportedLanguages()
+	sort.Strings(languages)
+
+	for _, language := range languages {
+		langConfig := languageMap[language]
+		if langConfig.DiagnosticCode == "" {
+			log.Printf("🩺 [%s] No sandbox probe program, skipped", language)
+			continue
+		}
+
+		outcomes, err := diagnoseSandbox(ctx, language, langConfig)
+		if err != nil {
+			log.Printf("❌ [%s] Sandbox diagnostic failed: %v", language, err)
+			continue
+		}
+
+		report := make([]string, 0, len(sandboxProbes))
+		for _, probe := range sandboxProbes {
+			outcome, ok := outcomes[probe]
+			switch {
+			case !ok:
+				outcome = "NO RESULT"
+			case outcome == "ok":
+			default:
+				outcome = "FAIL (" + strings.TrimPrefix(outcome, "fail:") + ")"
+			}
+			report = append(report, probe+": "+outcome)
+		}
+		log.Printf("🩺 [%s] Sandbox capabilities: %s", language, strings.Join(report, ", "))
+	}
+}
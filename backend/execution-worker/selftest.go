@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================================
+// Startup Self-Test
+// ============================================
+// Pulling an image proves it exists, not that it works in our sandbox.
+// With RUN_SELFTEST=true, every language runs a canned program through
+// the real execution path (nobody user, capabilities dropped, read-only
+// volume mount) before the worker accepts jobs. A misconfigured image or
+// an unreadable mount fails here instead of on the first submission.
+//
+// Results are reported per language; if any language fails, the worker
+// refuses to start.
+// ============================================
+
+// selfTestOutput is what every language's SelfTestCode must print
+const selfTestOutput = "rce-selftest-ok"
+
+var runSelfTest = getEnvBool("RUN_SELFTEST", false)
+
+// selfTest runs the language's canned program and checks its output
+func selfTest(ctx context.Context, language string) error {
+	langConfig, ok := languageMap[language]
+	if !ok {
+		return fmt.Errorf("unsupported language: %s", language)
+	}
+	if langConfig.SelfTestCode == "" {
+		return fmt.Errorf("no self-test program defined")
+	}
+
+	job := Job{
+		JobID:       fmt.Sprintf("selftest-%s-%d", language, time.Now().UnixNano()),
+		Language:    language,
+		Code:        langConfig.SelfTestCode,
+		SubmittedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	result, err := executionProvider.ExecuteCode(ctx, job)
+	if err != nil {
+		return err
+	}
+	if result.Status != "completed" {
+		return fmt.Errorf("status %s (exit code %d): %s", result.Status, result.ExitCode, result.Error)
+	}
+	if strings.TrimSpace(result.Output) != selfTestOutput {
+		return fmt.Errorf("unexpected output %q", truncate(result.Output, 100))
+	}
+	return nil
+}
+
+// runSelfTests self-tests every supported language and reports each result
+func runSelfTests(ctx context.Context) error {
+	languages := GetSupportedLanguages()
+	sort.Strings(languages)
+
+	var failed []string
+	for _, language := range languages {
+		if err := selfTest(ctx, language); err != nil {
+			log.Printf("❌ [%s] Self-test failed: %v", language, err)
+			failed = append(failed, language)
+			continue
+		}
+		log.Printf("✅ [%s] Self-test passed", language)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("self-test failed for: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
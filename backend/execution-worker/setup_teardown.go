@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ============================================
+// Setup and Teardown Scripts
+// ============================================
+// A job can carry a SetupScript that seeds fixtures before the user's
+// code runs (e.g. writes /tmp/input.csv) and a TeardownScript that runs
+// after it, both as sh scripts in the same container and as the same
+// user. The code directory is read-only, so fixtures go under /tmp.
+//
+// Each has its own timeout (SETUP_TIMEOUT and TEARDOWN_TIMEOUT, default
+// 5s), and the job's deadline is extended by both. Neither reads stdin,
+// which is left for the program.
+//
+// Their output is kept out of the program's: setup output is only shown
+// if setup fails, which aborts the job with status "setup_failed" (a
+// stage report, see stage_reports.go; the program exiting with
+// ExitCodeSetupFailed is just "failed").
+// Teardown output is discarded; a failing teardown is reported (with
+// the run's nonce) and logged, and doesn't change the result. A program
+// killed at its timeout gets no teardown.
+//
+// The scripts may hold what the program mustn't see (e.g. expected
+// answers), so they aren't passed in the environment, which the program
+// could read back from /proc. They are copied into the container's /tmp
+// as files (see copyControlFiles) that the wrapper reads into unexported
+// shell variables and deletes before the setup script runs.
+// ============================================
+
+var (
+	setupTimeout    = getEnvDuration("SETUP_TIMEOUT", 5*time.Second)
+	teardownTimeout = getEnvDuration("TEARDOWN_TIMEOUT", 5*time.Second)
+)
+
+// Where the wrapper finds the job's scripts
+const (
+	setupScriptFile    = "/tmp/.rce-setup.sh"
+	teardownScriptFile = "/tmp/.rce-teardown.sh"
+)
+
+// setupTeardownScript runs the setup script, the command in "$@" and
+// then the teardown script, exiting with the command's exit code
+var setupTeardownScript = readStageNonce(stageSetup) + fmt.Sprintf(`
+setup=$(cat %[1]s 2>/dev/null); teardown=$(cat %[2]s 2>/dev/null); rm -f %[1]s %[2]s
+if [ -n "$setup" ]; then
+  timeout "$RCE_SETUP_TIMEOUT" sh -c "$setup" </dev/null >/tmp/.rce-setup.log 2>&1 || { rc=$?; %[3]s; cat /tmp/.rce-setup.log >&2; exit %[4]d; }
+fi
+"$@"; status=$?
+if [ -n "$teardown" ]; then
+  timeout "$RCE_TEARDOWN_TIMEOUT" sh -c "$teardown" </dev/null >/dev/null 2>&1 || { rc=$?; %[5]s; }
+fi
+exit $status`, setupScriptFile, teardownScriptFile, reportStage("setup_failed"), ExitCodeSetupFailed, reportStage("teardown_failed"))
+
+// hasSetupOrTeardown reports whether the job's command must be wrapped
+func hasSetupOrTeardown(job Job) bool {
+	return job.SetupScript != "" || job.TeardownScript != ""
+}
+
+// wrapSetupTeardown wraps cmd so the job's setup and teardown run around it
+func wrapSetupTeardown(job Job, cmd []string) []string {
+	if !hasSetupOrTeardown(job) {
+		return cmd
+	}
+	return append([]string{"sh", "-c", setupTeardownScript, "sh"}, cmd...)
+}
+
+// setupTeardownFiles returns the script files to copy into the container
+func setupTeardownFiles(job Job) map[string]string {
+	files := make(map[string]string)
+	if job.SetupScript != "" {
+		files[setupScriptFile] = job.SetupScript
+	}
+	if job.TeardownScript != "" {
+		files[teardownScriptFile] = job.TeardownScript
+	}
+	return files
+}
+
+// setupTeardownEnv passes the scripts' timeouts (whole seconds) to the wrapper
+func setupTeardownEnv(job Job) []string {
+	if !hasSetupOrTeardown(job) {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("RCE_SETUP_TIMEOUT=%d", wholeSeconds(setupTimeout)),
+		fmt.Sprintf("RCE_TEARDOWN_TIMEOUT=%d", wholeSeconds(teardownTimeout)),
+	}
+}
+
+// setupTeardownTimeout is the extra time the job's scripts may take
+func setupTeardownTimeout(job Job) time.Duration {
+	var extra time.Duration
+	if job.SetupScript != "" {
+		extra += setupTimeout
+	}
+	if job.TeardownScript != "" {
+		extra += teardownTimeout
+	}
+	return extra
+}
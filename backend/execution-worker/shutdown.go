@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ============================================
+// Shutdown
+// ============================================
+// Subsystems are stopped in dependency order, all under one overall
+// deadline (SHUTDOWN_TIMEOUT, default 8s, inside Docker's default 10s
+// stop grace period):
+//   1. Containers      - running executions are stopped gracefully
+//                        (see container_stop.go)
+//   2. Worker loop     - the in-flight job is aborted and requeued
+//   3. Analysis        - queued analysis notifications are published
+//                        (see analysis_notify.go)
+//   4. Execution stats - final flush of the counts to MongoDB
+//   5. HTTP server     - in-flight requests (e.g. scrapes) complete
+//   6. Webhooks        - queued deliveries are attempted, and any
+//                        left are recorded as not delivered
+//                        (see webhook.go)
+//   7. Background jobs - stuck job reaper, queue depth sampler and
+//                        debug container sweeper stop
+//   8. Job source      - queue connection closed
+//   9. Provider        - Docker client closed
+//  10. Connections     - Redis and MongoDB closed last, as every step
+//                        before may still use them
+// A step that fails or runs out of time is logged and the remaining
+// steps still run, so connections are always closed.
+// ============================================
+
+var shutdownTimeout = getEnvDuration("SHUTDOWN_TIMEOUT", 8*time.Second)
+
+// shutdownStep is one subsystem to stop
+type shutdownStep struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// runShutdown runs each step in order under a shared deadline
+func runShutdown(steps []shutdownStep) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, step := range steps {
+		if err := step.stop(ctx); err != nil {
+			log.Printf("⚠️  Shutdown: %s: %v", step.name, err)
+			continue
+		}
+		log.Printf("🛑 Shutdown: %s stopped", step.name)
+	}
+	log.Printf("👋 Shutdown finished in %v", time.Since(start).Round(time.Millisecond))
+}
+
+// waitFor blocks until done is closed or ctx expires
+func waitFor(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("did not stop before the shutdown deadline")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ============================================
+// Stage Reports
+// ============================================
+// The compile step (see compile.go) and a job's setup script (see
+// setup_teardown.go) run in the execution container before the program,
+// and its teardown script after it. Their outcome can't be told from the
+// container's exit code alone: the program can exit with any code,
+// including ExitCodeCompileError, and write anything to stderr.
+//
+// Instead, each wrapper reports a failed stage out of band, as a line on
+// stderr:
+//
+//	__RCE_STAGE__ <nonce> <stage status> <exit code>
+//
+// The nonce is random per run. Before the container starts, the worker
+// copies it into the container's /tmp, one file per wrapper, owned by
+// the user the code runs as. Each wrapper reads its file and deletes it
+// before running anything from the submission, keeping the nonce in an
+// unexported shell variable, so the program (or its compile) never sees
+// it and can't forge a report. ExecuteCode only trusts statuses from
+// lines carrying the run's nonce; others are left in the output.
+// ============================================
+
+// stageMarker prefixes a wrapper's stage report line
+const stageMarker = "__RCE_STAGE__"
+
+// Wrappers that report stages, each with its own nonce file
+const (
+	stageCompile = "compile"
+	stageSetup   = "setup"
+)
+
+// stageNonceFile is where a wrapper finds the run's nonce
+func stageNonceFile(stage string) string {
+	return "/tmp/.rce-nonce-" + stage
+}
+
+// readStageNonce is the shell snippet a wrapper starts with: it reads
+// its nonce into $n and deletes the file
+func readStageNonce(stage string) string {
+	return fmt.Sprintf(`n=$(cat %[1]s 2>/dev/null); rm -f %[1]s; `, stageNonceFile(stage))
+}
+
+// reportStage is the shell snippet reporting status for the exit code in $rc
+func reportStage(status string) string {
+	return fmt.Sprintf(`echo "%s $n %s $rc" >&2`, stageMarker, status)
+}
+
+// stageReport is a stage status reported by a wrapper
+type stageReport struct {
+	status   string // e.g. "compile_error"
+	exitCode string // Exit code of the stage's command
+}
+
+// newStageNonce returns a random nonce for a run's stage reports
+func newStageNonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "" // No reports can be trusted; stages fall back to "failed"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// copyControlFiles writes files (paths under /tmp -> content), such as
+// the stages' nonce files, into a created (not yet started) container
+func (dp *DockerProvider) copyControlFiles(ctx context.Context, containerID string, files map[string]string) error {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for file, content := range files {
+		name := strings.TrimPrefix(file, "/tmp/")
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}); err != nil {
+			return err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	// CopyUIDGID gives the files to the container's user, so the wrapper can delete them
+	return dp.client.CopyToContainer(ctx, containerID, "/tmp", &archive, container.CopyToContainerOptions{CopyUIDGID: true})
+}
+
+// extractStageReports removes the report lines carrying nonce from the
+// captured output (and its raw and stderr copies) and returns them
+func extractStageReports(jobID, nonce string, captured *capturedOutput) []stageReport {
+	if nonce == "" {
+		return nil
+	}
+
+	prefix := stageMarker + " " + nonce + " "
+	var reports []stageReport
+	strip := func(output string, collect bool) string {
+		if !strings.Contains(output, prefix) {
+			return output
+		}
+		lines := strings.Split(output, "\n")
+		kept := lines[:0]
+		for _, line := range lines {
+			// A report follows whatever the program last wrote to stderr,
+			// which may not have ended its line
+			i := strings.Index(line, prefix)
+			if i < 0 {
+				kept = append(kept, line)
+				continue
+			}
+			if i > 0 {
+				kept = append(kept, line[:i])
+			}
+			report := strings.TrimRight(line[i+len(prefix):], "\r")
+			if collect {
+				status, exitCode, _ := strings.Cut(report, " ")
+				reports = append(reports, stageReport{status: status, exitCode: exitCode})
+			}
+		}
+		return strings.Join(kept, "\n")
+	}
+
+	captured.Text = strip(captured.Text, true)
+	captured.Raw = strip(captured.Raw, false)
+	captured.Stderr = strip(captured.Stderr, false)
+	for _, report := range reports {
+		log.Printf("🧾 [%s] Stage report: %s (exit code %s)", jobID, report.status, report.exitCode)
+	}
+	return reports
+}
+
+// stageStatus returns the status a stage reported, if any did
+func stageStatus(reports []stageReport, statuses ...string) (stageReport, bool) {
+	for _, report := range reports {
+		for _, status := range statuses {
+			if report.status == status {
+				return report, true
+			}
+		}
+	}
+	return stageReport{}, false
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ============================================
+// Status Writes
+// ============================================
+// By the time processJob marks a job "processing" in MongoDB, the job
+// has already been taken off the queue. If that write fails (e.g. a
+// brief MongoDB outage), giving up would lose the job, so
+// PROCESSING_STATUS_FAILURE decides what happens instead:
+//
+//   - continue (default): log a warning and run the job anyway; the
+//     submission just stays "queued" until its final status is written
+//   - requeue: put the job back on the queue (counting as a retry, see
+//     job_retry.go) to run once MongoDB is reachable again
+//
+// Either way the final status write is what matters, so it is tried up
+// to STATUS_WRITE_ATTEMPTS times (default 3) with a doubling wait from
+// 500ms, rather than once.
+// ============================================
+
+var (
+	processingStatusFailure = getEnv("PROCESSING_STATUS_FAILURE", "continue")
+	statusWriteAttempts     = getEnvInt("STATUS_WRITE_ATTEMPTS", 3)
+)
+
+// statusWriteRetryInterval is the wait before the first final status write retry
+const statusWriteRetryInterval = 500 * time.Millisecond
+
+// validateProcessingStatusFailure checks PROCESSING_STATUS_FAILURE
+func validateProcessingStatusFailure() error {
+	if processingStatusFailure != "continue" && processingStatusFailure != "requeue" {
+		return fmt.Errorf("PROCESSING_STATUS_FAILURE must be continue or requeue, got %q", processingStatusFailure)
+	}
+	return nil
+}
+
+// markProcessing sets the job's status to "processing" and reports whether
+// processJob should go on to run it
+func markProcessing(ctx context.Context, job Job, queueTime time.Duration) bool {
+	err := updateJobStatus(ctx, job.JobID, "processing", nil)
+	if err == nil {
+		log.Printf("📊 Job [%s] status updated to: processing", job.JobID)
+		return true
+	}
+
+	if processingStatusFailure == "requeue" {
+		log.Printf("❌ [%s] Failed to update status to processing, requeueing: %v", job.JobID, err)
+		requeueJob(job, fmt.Sprintf("failed to update status to processing: %v", err), queueTime)
+		return false
+	}
+	log.Printf("⚠️  [%s] Failed to update status to processing, running anyway: %v", job.JobID, err)
+	return true
+}
+
+// writeFinalStatus stores a job's terminal status and result, retrying
+// transient failures
+func writeFinalStatus(ctx context.Context, jobID, status string, result *ExecutionResult) error {
+	name := fmt.Sprintf("MongoDB status write for %s", jobID)
+	return retryWithBackoff(ctx, name, statusWriteAttempts, statusWriteRetryInterval, func() error {
+		return updateJobStatus(ctx, jobID, status, result)
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ============================================
+// Program Input (stdin)
+// ============================================
+// A job's input is given either inline (Stdin) or, for large inputs that
+// shouldn't travel through the queue, as StdinRef: the key of a Redis
+// string holding the input. The worker fetches it before execution.
+//
+// StdinParts instead gives the input in pieces (e.g. a shared fixture
+// followed by a per-user case), which the program reads concatenated in
+// order, as if they had been sent as one Stdin.
+//
+// SECURITY: StdinRef may only name keys under STDIN_REF_PREFIX (default
+// "stdin:"), so a job can't read arbitrary Redis data into its program.
+//
+// Either way the job carries the resolved input as Stdin from then on,
+// but a requeued or dead-lettered job is serialized with its StdinRef or
+// StdinParts as queued (see withQueuedStdin), so a large input still
+// never travels through the queue.
+//
+// The input is streamed to the container's stdin over the attach
+// connection, which is then closed so the program sees EOF. The result's
+// StdinConsumed reports whether all of it was delivered before the
+// program exited or closed its stdin.
+//
+// Limits:
+//   MAX_STDIN_BYTES - max input size, inline, referenced or in parts (default 5MB)
+// ============================================
+
+var (
+	maxStdinBytes  = int64(getEnvInt("MAX_STDIN_BYTES", 5*1024*1024))
+	stdinRefPrefix = getEnv("STDIN_REF_PREFIX", "stdin:")
+)
+
+// queuedStdin is a job's input as it was queued, before resolveStdin
+type queuedStdin struct {
+	ref   string
+	parts []string
+}
+
+// resolveStdin validates the job's input and replaces a StdinRef with
+// the referenced content
+func resolveStdin(ctx context.Context, job *Job) error {
+	if len(job.StdinParts) > 0 {
+		return joinStdinParts(job)
+	}
+
+	if job.StdinRef == "" {
+		if int64(len(job.Stdin)) > maxStdinBytes {
+			return fmt.Errorf("stdin is %d bytes, limit is %d", len(job.Stdin), maxStdinBytes)
+		}
+		return nil
+	}
+
+	if job.Stdin != "" {
+		return fmt.Errorf("stdin and stdinRef are mutually exclusive")
+	}
+	if !strings.HasPrefix(job.StdinRef, stdinRefPrefix) {
+		return fmt.Errorf("stdinRef %q must start with %q", job.StdinRef, stdinRefPrefix)
+	}
+
+	// Check the size before fetching, so an oversized input is never loaded
+	size, err := redisClient.StrLen(ctx, job.StdinRef).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read stdinRef %s: %w", job.StdinRef, err)
+	}
+	if size > maxStdinBytes {
+		return fmt.Errorf("stdinRef %s is %d bytes, limit is %d", job.StdinRef, size, maxStdinBytes)
+	}
+
+	content, err := redisClient.Get(ctx, job.StdinRef).Result()
+	if err != nil {
+		// Also covers a missing key (StrLen reports 0 for those)
+		return fmt.Errorf("failed to read stdinRef %s: %w", job.StdinRef, err)
+	}
+
+	log.Printf("📥 [%s] Loaded %d bytes of stdin from %s", job.JobID, len(content), job.StdinRef)
+	job.queuedStdin = &queuedStdin{ref: job.StdinRef}
+	job.Stdin = content
+	job.StdinRef = ""
+	return nil
+}
+
+// joinStdinParts replaces the job's StdinParts with their concatenation
+func joinStdinParts(job *Job) error {
+	if job.Stdin != "" || job.StdinRef != "" {
+		return fmt.Errorf("stdinParts can't be combined with stdin or stdinRef")
+	}
+
+	var total int64
+	for _, part := range job.StdinParts {
+		total += int64(len(part))
+	}
+	if total > maxStdinBytes {
+		return fmt.Errorf("stdinParts total %d bytes, limit is %d", total, maxStdinBytes)
+	}
+
+	job.queuedStdin = &queuedStdin{parts: job.StdinParts}
+	job.Stdin = strings.Join(job.StdinParts, "")
+	job.StdinParts = nil
+	return nil
+}
+
+// withQueuedStdin returns the job with its input as it was queued, for
+// serializing it again
+func (job Job) withQueuedStdin() Job {
+	if job.queuedStdin == nil {
+		return job
+	}
+	job.Stdin = ""
+	job.StdinRef = job.queuedStdin.ref
+	job.StdinParts = job.queuedStdin.parts
+	job.queuedStdin = nil
+	return job
+}
+
+// stdinWaitTimeout bounds how long stdinConsumed waits for the write to finish
+const stdinWaitTimeout = time.Second
+
+// writeStdin sends input to the attached container and closes its stdin.
+// Run in a goroutine: it blocks while the program isn't reading. A
+// program that exits or closes stdin early is normal, not a job failure.
+func (a *attachedOutput) writeStdin(jobID, input string) {
+	defer close(a.stdinDone)
+
+	if _, err := a.stream.Conn.Write([]byte(input)); err != nil {
+		log.Printf("ℹ️  [%s] Program did not read all of its stdin: %v", jobID, err)
+		return
+	}
+	if err := a.stream.CloseWrite(); err != nil {
+		log.Printf("ℹ️  [%s] Failed to close stdin: %v", jobID, err)
+		return
+	}
+	a.stdinWritten = true
+}
+
+// stdinConsumed reports whether all of stdin was delivered, once the program has exited
+func (a *attachedOutput) stdinConsumed() bool {
+	select {
+	case <-a.stdinDone:
+		return a.stdinWritten
+	case <-time.After(stdinWaitTimeout):
+		// Still blocked: the program never read the rest
+		return false
+	}
+}
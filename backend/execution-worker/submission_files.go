@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ============================================
+// Submission Files
+// ============================================
+// A job is either a single Code string (written as script<ext>) or a set
+// of Files, optionally alongside Code. EntryPoint picks what to run:
+//   - a submitted file:           python3 /code/<jobId>/main.py
+//   - a package (ModuleFlag set): python3 -m mypkg  (needs mypkg/__main__.py)
+// With no EntryPoint, script<ext> is run, or the only file if there's one.
+//
+// A language may wrap file entrypoints in an EntrypointTemplate, e.g.
+//   ["sh", "-c", "ulimit -s 8192; exec python3 \"$1\"", "sh", "{{SCRIPT}}"]
+// Without one, the file is run directly: <Executor> <file>.
+//
+// Executor paths vary across image versions (python3 vs python). A
+// language can list candidate Executors instead; the container then runs
+// the first one found on its PATH, with no extra container or retry.
+//
+// MAX_FILES_PER_JOB (default 50, 0 disables) caps how many Files a job
+// may submit, checked before anything is written, so thousands of tiny
+// files can't stress the execution volume. Filenames must be relative,
+// without "..", control characters or empty segments, and at most
+// maxFileNameLength bytes.
+// ============================================
+
+var maxFilesPerJob = getEnvInt("MAX_FILES_PER_JOB", 50)
+
+// maxFileNameLength caps a submitted file's relative path (a common filesystem NAME_MAX)
+const maxFileNameLength = 255
+
+// entryPoint is the resolved target a container runs
+type entryPoint struct {
+	Name   string // Relative file path, or package name when Module is set
+	Module bool   // Run with the language's ModuleFlag instead of as a file
+
+	LineOffset int  // Harness lines before the user's code in the entrypoint file
+	Eval       bool // Run through the language's eval wrapper (see eval_mode.go)
+	Stdin      bool // The code is piped in, there is no file (see code_delivery.go)
+}
+
+// defaultScriptName returns the filename used for a job's inline Code
+func defaultScriptName(langConfig LanguageConfig) string {
+	return "script" + langConfig.Extension
+}
+
+// codeFiles returns every file of a job keyed by its cleaned relative path
+func codeFiles(job Job, langConfig LanguageConfig) (map[string]string, error) {
+	if maxFilesPerJob > 0 && len(job.Files) > maxFilesPerJob {
+		return nil, fmt.Errorf("submission has %d files, limit is %d", len(job.Files), maxFilesPerJob)
+	}
+
+	files := make(map[string]string, len(job.Files)+1)
+	for name, content := range job.Files {
+		cleaned, err := validateFileName(name)
+		if err != nil {
+			return nil, err
+		}
+		files[cleaned] = content
+	}
+
+	if job.Code != "" || len(files) == 0 {
+		files[defaultScriptName(langConfig)] = job.Code
+	}
+
+	return files, nil
+}
+
+// validateFileName rejects paths that could escape the execution directory
+func validateFileName(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty filename")
+	}
+	if len(name) > maxFileNameLength {
+		return "", fmt.Errorf("invalid filename %.32q...: longer than %d bytes", name, maxFileNameLength)
+	}
+	if strings.HasPrefix(name, "/") || strings.Contains(name, "\\") {
+		return "", fmt.Errorf("invalid filename %q: must be a relative path", name)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("invalid filename %q: must not contain control characters", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid filename %q: must not contain '..'", name)
+		}
+		if part == "" {
+			return "", fmt.Errorf("invalid filename %q: must not contain empty path segments", name)
+		}
+	}
+	return path.Clean(name), nil
+}
+
+// resolveEntryPoint validates the requested entrypoint against the submitted files
+func resolveEntryPoint(job Job, langConfig LanguageConfig, files map[string]string) (entryPoint, error) {
+	if job.EntryPoint == "" {
+		name := defaultScriptName(langConfig)
+		if _, ok := files[name]; !ok && len(files) == 1 {
+			for only := range files {
+				name = only
+			}
+		}
+		if _, ok := files[name]; !ok {
+			return entryPoint{}, fmt.Errorf("no entrypoint specified and no %s submitted", name)
+		}
+		return entryPoint{Name: name}, nil
+	}
+
+	name, err := validateFileName(job.EntryPoint)
+	if err != nil {
+		return entryPoint{}, err
+	}
+
+	if _, ok := files[name]; ok {
+		return entryPoint{Name: name}, nil
+	}
+
+	// e.g. "mypkg" -> python3 -m mypkg
+	if langConfig.ModuleFlag != "" {
+		if _, ok := files[path.Join(name, "__main__"+langConfig.Extension)]; ok {
+			return entryPoint{Name: name, Module: true}, nil
+		}
+	}
+
+	return entryPoint{}, fmt.Errorf("entrypoint %q is not one of the submitted files", job.EntryPoint)
+}
+
+// hasRunnableCode reports whether the file the job would run has any
+// non-whitespace content. Jobs for unsupported languages or with an invalid
+// entrypoint are passed through so ExecuteCode reports the real problem.
+func hasRunnableCode(job Job) bool {
+	langConfig, ok := languageMap[job.Language]
+	if !ok {
+		return true
+	}
+
+	files, err := codeFiles(job, langConfig)
+	if err != nil {
+		return true
+	}
+	entry, err := resolveEntryPoint(job, langConfig, files)
+	if err != nil {
+		return true
+	}
+
+	name := entry.Name
+	if entry.Module {
+		name = path.Join(entry.Name, "__main__"+langConfig.Extension)
+	}
+	return strings.TrimSpace(files[name]) != ""
+}
+
+// writeSubmissionFiles writes all of a job's files into execDir and returns the resolved entrypoint
+func writeSubmissionFiles(execDir string, job Job, langConfig LanguageConfig) (entryPoint, error) {
+	files, err := codeFiles(job, langConfig)
+	if err != nil {
+		return entryPoint{}, err
+	}
+
+	entry, err := resolveEntryPoint(job, langConfig, files)
+	if err != nil {
+		return entryPoint{}, err
+	}
+
+	if job.UseHarness {
+		if langConfig.HarnessTemplate == "" {
+			return entryPoint{}, fmt.Errorf("language %s has no harness template", job.Language)
+		}
+		if entry.Module {
+			return entryPoint{}, fmt.Errorf("a harness can't wrap package entrypoint %q", entry.Name)
+		}
+		files[entry.Name], entry.LineOffset, err = applyHarness(langConfig.HarnessTemplate, files[entry.Name])
+		if err != nil {
+			return entryPoint{}, err
+		}
+	}
+
+	if job.Mode == modeEval {
+		if entry.Module {
+			return entryPoint{}, fmt.Errorf("eval mode can't run package entrypoint %q", entry.Name)
+		}
+		entry.Eval = true
+	}
+
+	for name, content := range files {
+		target := filepath.Join(execDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return entryPoint{}, err
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return entryPoint{}, err
+		}
+	}
+
+	return entry, nil
+}
+
+// scriptPlaceholder is replaced by the entrypoint file's path in an EntrypointTemplate
+const scriptPlaceholder = "{{SCRIPT}}"
+
+// buildExecuteCmd builds the container command that runs the entrypoint.
+// A file entrypoint uses the language's EntrypointTemplate when it has one.
+func buildExecuteCmd(langConfig LanguageConfig, workDir string, entry entryPoint) []string {
+	if entry.Stdin {
+		return executorCommand(langConfig, langConfig.StdinArgs...)
+	}
+	if entry.Eval {
+		return buildEvalCmd(langConfig, workDir, entry)
+	}
+	if entry.Module {
+		return executorCommand(langConfig, langConfig.ModuleFlag, strings.ReplaceAll(entry.Name, "/", "."))
+	}
+
+	script := path.Join(workDir, entry.Name)
+	if len(langConfig.EntrypointTemplate) == 0 {
+		return executorCommand(langConfig, script)
+	}
+
+	cmd := make([]string, len(langConfig.EntrypointTemplate))
+	for i, arg := range langConfig.EntrypointTemplate {
+		if arg == scriptPlaceholder {
+			arg = script
+		}
+		cmd[i] = arg
+	}
+	return cmd
+}
+
+// executorCommand runs args with the language's executor. With more
+// than one candidate, sh picks the first found (exit 127 if none is).
+func executorCommand(langConfig LanguageConfig, args ...string) []string {
+	candidates := langConfig.Executors
+	if len(candidates) == 0 {
+		candidates = []string{langConfig.Executor}
+	}
+	if len(candidates) == 1 {
+		return append([]string{candidates[0]}, args...)
+	}
+
+	list := strings.Join(candidates, " ")
+	script := fmt.Sprintf(`for e in %s; do command -v "$e" >/dev/null 2>&1 && exec "$e" "$@"; done; echo "no executor found (tried: %s)" >&2; exit 127`, list, list)
+	return append([]string{"sh", "-c", script, "sh"}, args...)
+}
+
+// executorPattern is what a candidate executor may look like, as it's spliced into a shell script
+var executorPattern = regexp.MustCompile(`^[A-Za-z0-9._/+-]+$`)
+
+// validateExecutors checks every language's candidate Executors
+func validateExecutors() error {
+	for name, langConfig := range languageMap {
+		for _, executor := range langConfig.Executors {
+			if !executorPattern.MatchString(executor) {
+				return fmt.Errorf("%s: invalid executor %q", name, executor)
+			}
+		}
+	}
+	return nil
+}
+
+// validateEntrypointTemplates checks every language's EntrypointTemplate.
+// {{SCRIPT}} must be a whole argument: user-chosen filenames are never
+// spliced into a shell string (use "$1" in sh -c instead).
+func validateEntrypointTemplates() error {
+	for name, langConfig := range languageMap {
+		if len(langConfig.EntrypointTemplate) == 0 {
+			continue
+		}
+
+		found := false
+		for _, arg := range langConfig.EntrypointTemplate {
+			if arg == scriptPlaceholder {
+				found = true
+			} else if strings.Contains(arg, "{{") {
+				return fmt.Errorf("%s: entrypoint template argument %q: placeholders must be a whole argument", name, arg)
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: entrypoint template %v has no %s argument", name, langConfig.EntrypointTemplate, scriptPlaceholder)
+		}
+	}
+	return nil
+}
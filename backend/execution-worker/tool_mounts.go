@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// ============================================
+// Per-Language Tool Mounts
+// ============================================
+// A LanguageConfig can list ExtraMounts: host directories (a linter, a
+// fixed library directory) bind-mounted into every execution container
+// for that language, so tooling can be added without rebuilding the
+// base image.
+//
+// Extra mounts are always read-only, so a submission can't modify the
+// tooling seen by later jobs. Sources are host paths; the worker checks
+// them at startup, so they must also be visible to the worker at the
+// same path (mount them into the worker container when it runs in one).
+// ============================================
+
+// MountSpec is a read-only bind mount from the Docker host into execution containers
+type MountSpec struct {
+	Source string // Absolute path on the host
+	Target string // Absolute path inside the container
+}
+
+// reservedMountTargets are used by the worker's own mounts
+var reservedMountTargets = []string{"/code", artifactMountPath}
+
+// validateExtraMounts checks every language's ExtraMounts at startup
+func validateExtraMounts() error {
+	for name, langConfig := range languageMap {
+		for _, spec := range langConfig.ExtraMounts {
+			if !filepath.IsAbs(spec.Source) {
+				return fmt.Errorf("%s: mount source %q must be an absolute path", name, spec.Source)
+			}
+			if !path.IsAbs(spec.Target) {
+				return fmt.Errorf("%s: mount target %q must be an absolute path", name, spec.Target)
+			}
+			for _, reserved := range reservedMountTargets {
+				if path.Clean(spec.Target) == reserved {
+					return fmt.Errorf("%s: mount target %s is reserved", name, reserved)
+				}
+			}
+			if _, err := os.Stat(spec.Source); err != nil {
+				return fmt.Errorf("%s: mount source: %w", name, err)
+			}
+			log.Printf("🧰 [%s] Extra mount: %s -> %s (read-only)", name, spec.Source, spec.Target)
+		}
+	}
+	return nil
+}
+
+// extraMounts returns a language's tool mounts for ContainerCreate
+func extraMounts(langConfig LanguageConfig) []mount.Mount {
+	mounts := make([]mount.Mount, 0, len(langConfig.ExtraMounts))
+	for _, spec := range langConfig.ExtraMounts {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   spec.Source,
+			Target:   spec.Target,
+			ReadOnly: true,
+		})
+	}
+	return mounts
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// ============================================
+// Variants (A/B Testing)
+// ============================================
+// To compare two sets of limits or two base images on success rate,
+// a job can carry a Variant (assigned upstream) naming one of the
+// variants configured with VARIANTS, a JSON object keyed by name:
+//
+//	VARIANTS='{"medium-limits":{"profile":"medium"},"py313":{"images":{"python":"python:3.13-slim"}}}'
+//
+//   - profile replaces the job's resource profile
+//   - images replace the image of the listed languages (any pinned
+//     IMAGE_DIGEST_<LANG> belongs to the default image and is dropped)
+//
+// Jobs without a Variant get DEFAULT_VARIANT (default "control"). The
+// built-in "control" variant changes nothing; VARIANTS may redefine it.
+// An unknown variant fails the job.
+//
+// The variant is recorded in the result as "variant", as a label of
+// rce_executions_total{language,variant,status} and as a field of the
+// "stats" documents (see execution_stats.go), so outcomes can be
+// compared across variants.
+// ============================================
+
+var defaultVariant = getEnv("DEFAULT_VARIANT", variantControl)
+
+// variantControl is the built-in variant that applies no overrides
+const variantControl = "control"
+
+// Variant is a named set of overrides applied to a job's execution
+type Variant struct {
+	Name    string            `json:"-"`
+	Profile string            `json:"profile,omitempty"` // Resource profile replacing the job's
+	Images  map[string]string `json:"images,omitempty"`  // Language -> image replacing the language's
+}
+
+// variants is the variant registry, populated by loadVariants
+var variants = map[string]Variant{
+	variantControl: {},
+}
+
+// loadVariants merges VARIANTS into the registry and validates it.
+// Must be called after loadResourceProfiles and before the worker loop starts.
+func loadVariants() error {
+	if raw := getEnv("VARIANTS", ""); raw != "" {
+		var custom map[string]Variant
+		if err := json.Unmarshal([]byte(raw), &custom); err != nil {
+			return fmt.Errorf("invalid VARIANTS: %w", err)
+		}
+		for name, variant := range custom {
+			variants[name] = variant
+		}
+	}
+
+	for name, variant := range variants {
+		if variant.Profile != "" {
+			if _, err := resolveProfile(variant.Profile); err != nil {
+				return fmt.Errorf("variant %q: %w", name, err)
+			}
+		}
+		for language, image := range variant.Images {
+			if _, ok := languageMap[language]; !ok {
+				return fmt.Errorf("variant %q: unsupported language %q", name, language)
+			}
+			if image == "" {
+				return fmt.Errorf("variant %q: empty image for %s", name, language)
+			}
+		}
+		variant.Name = name
+		variants[name] = variant
+	}
+
+	if _, ok := variants[defaultVariant]; !ok {
+		return fmt.Errorf("default variant %q is not defined", defaultVariant)
+	}
+
+	if len(variants) > 1 {
+		names := make([]string, 0, len(variants))
+		for name := range variants {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		log.Printf("🧪 Variants: %v (default %s)", names, defaultVariant)
+	}
+	return nil
+}
+
+// resolveVariant returns the named variant, or the default when name is empty
+func resolveVariant(name string) (Variant, error) {
+	if name == "" {
+		name = defaultVariant
+	}
+	variant, ok := variants[name]
+	if !ok {
+		return Variant{}, fmt.Errorf("unknown variant: %s", name)
+	}
+	return variant, nil
+}
+
+// variantLabel names a job's variant for metrics and stats; unknown
+// names are grouped so they can't grow the label set
+func variantLabel(job Job) string {
+	variant, err := resolveVariant(job.Variant)
+	if err != nil {
+		return "unknown"
+	}
+	return variant.Name
+}
+
+// apply returns the job and language config with the variant's overrides
+func (v Variant) apply(job Job, langConfig LanguageConfig) (Job, LanguageConfig) {
+	if v.Profile != "" {
+		job.Profile = v.Profile
+	}
+	if image, ok := v.Images[job.Language]; ok {
+		langConfig.Image = image
+		langConfig.ImageDigest = ""
+	}
+	return job, langConfig
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ============================================
+// Warm Sandbox Images
+// ============================================
+// For languages where cold start dominates (e.g. JVM warmup, class
+// loading), a LanguageConfig can name a BaseImage and a WarmCmd.
+// At startup the worker runs WarmCmd once inside BaseImage (for example
+// generating a class-data-sharing archive) and commits the resulting
+// container as LanguageConfig.Image. Executions then start from the
+// warmed image.
+//
+// TRADEOFF: each warmed image costs extra disk on the host, and whatever
+// the warm step caches is loaded into every execution container's memory.
+// In exchange, per-execution startup latency drops.
+//
+// The warm image is built once per host: if Image already exists
+// locally, the build is skipped. If warming fails, the language falls
+// back to running directly on BaseImage.
+// ============================================
+
+// warmBuildTimeout bounds a single warm image build (pull + warm + commit)
+const warmBuildTimeout = 5 * time.Minute
+
+// PrepareWarmImages builds the warmed image for every language that defines one.
+// Must be called before the worker loop starts, as it may update languageMap.
+func (dp *DockerProvider) PrepareWarmImages(ctx context.Context) {
+	for name, langConfig := range languageMap {
+		if langConfig.BaseImage == "" || len(langConfig.WarmCmd) == 0 {
+			continue
+		}
+
+		if err := dp.buildWarmImage(ctx, name, langConfig); err != nil {
+			log.Printf("⚠️  [%s] Warm image unavailable, falling back to %s: %v", name, langConfig.BaseImage, err)
+			langConfig.Image = langConfig.BaseImage
+			languageMap[name] = langConfig
+			continue
+		}
+		log.Printf("🔥 [%s] Warm image ready: %s", name, langConfig.Image)
+	}
+}
+
+// buildWarmImage runs the language's WarmCmd in its BaseImage and commits the result as Image
+func (dp *DockerProvider) buildWarmImage(ctx context.Context, language string, langConfig LanguageConfig) error {
+	ctx, cancel := context.WithTimeout(ctx, warmBuildTimeout)
+	defer cancel()
+
+	// Already built on this host
+	if _, _, err := dp.client.ImageInspectWithRaw(ctx, langConfig.Image); err == nil {
+		return nil
+	}
+
+	if _, err := dp.ensureImage(ctx, langConfig.BaseImage); err != nil {
+		return err
+	}
+
+	log.Printf("🔥 [%s] Warming %s with %v", language, langConfig.BaseImage, langConfig.WarmCmd)
+
+	// The warm step runs trusted commands only, but is still kept offline
+	resp, err := dp.client.ContainerCreate(
+		ctx,
+		&container.Config{
+			Image:           langConfig.BaseImage,
+			Cmd:             langConfig.WarmCmd,
+			NetworkDisabled: true,
+			Labels:          dp.containerLabels(Job{JobID: "warm-" + language}, 0),
+		},
+		&container.HostConfig{
+			SecurityOpt: []string{"no-new-privileges"},
+		},
+		nil,
+		dp.platform,
+		dp.containerPrefix+"warm-"+language,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create warm container: %w", err)
+	}
+
+	defer func() {
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cleanupCancel()
+		dp.removeContainer(cleanupCtx, resp.ID, "warm-"+language)
+	}()
+
+	if err := dp.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start warm container: %w", err)
+	}
+
+	statusCh, errCh := dp.client.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("warm container wait error: %w", err)
+		}
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("warm command exited with code %d", status.StatusCode)
+		}
+	}
+
+	if _, err := dp.client.ContainerCommit(ctx, resp.ID, container.CommitOptions{
+		Reference: langConfig.Image,
+		Comment:   fmt.Sprintf("RCE warm image for %s", language),
+	}); err != nil {
+		return fmt.Errorf("failed to commit warm image: %w", err)
+	}
+
+	return nil
+}
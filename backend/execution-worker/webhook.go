@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ============================================
+// Result Webhooks
+// ============================================
+// A job may carry a CallbackURL. Once its terminal status is stored,
+// the result JSON is POSTed there by a bounded pool of delivery
+// goroutines, so a slow webhook never blocks job processing.
+//
+// SECURITY: to prevent SSRF, the URL's host must appear in
+// WEBHOOK_ALLOWED_HOSTS (comma-separated). With no allowlist, webhooks
+// are disabled. Redirects are never followed.
+//
+// Delivery outcome is recorded on the submission document under "webhook".
+//
+// On shutdown, queued deliveries (and retries waiting out their backoff)
+// keep going until WEBHOOK_SHUTDOWN_GRACE (default 1s) before the
+// shutdown deadline. Whatever is still undelivered then, or enqueued
+// after the dispatcher stopped, is recorded as "not delivered: worker
+// shut down" rather than silently dropped.
+// ============================================
+
+// webhookDelivery is a single queued callback
+type webhookDelivery struct {
+	jobID   string
+	url     string
+	payload []byte
+}
+
+// webhookShutdownError is recorded for deliveries abandoned by shutdown
+const webhookShutdownError = "not delivered: worker shut down"
+
+// webhookRecordTimeout bounds recording an outcome once deliveries are cancelled
+const webhookRecordTimeout = 2 * time.Second
+
+var (
+	webhookAllowedHosts  = parseHostList(getEnv("WEBHOOK_ALLOWED_HOSTS", ""))
+	webhookMaxAttempts   = getEnvInt("WEBHOOK_MAX_ATTEMPTS", 3)
+	webhookTimeout       = getEnvDuration("WEBHOOK_TIMEOUT", 5*time.Second)
+	webhookQueueSize     = getEnvInt("WEBHOOK_QUEUE_SIZE", 100)
+	webhookShutdownGrace = getEnvDuration("WEBHOOK_SHUTDOWN_GRACE", 1*time.Second)
+
+	webhookClient = &http.Client{
+		Timeout: webhookTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse // SECURITY: a redirect could point anywhere
+		},
+	}
+)
+
+// webhookDispatcher is the pool of delivery goroutines
+type webhookDispatcher struct {
+	mu     sync.RWMutex // Held for writing only to close queue
+	closed bool
+	queue  chan webhookDelivery
+	done   chan struct{}
+
+	ctx    context.Context // Deliveries' context, cancelled near the shutdown deadline
+	cancel context.CancelFunc
+}
+
+// webhooks is the process-wide dispatcher; nil when webhooks are disabled
+var webhooks *webhookDispatcher
+
+// startWebhookDispatcher starts the webhook delivery goroutines, unless
+// webhooks are disabled
+func startWebhookDispatcher() *webhookDispatcher {
+	if len(webhookAllowedHosts) == 0 {
+		log.Println("🔕 Webhooks disabled (WEBHOOK_ALLOWED_HOSTS not set)")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &webhookDispatcher{
+		queue:  make(chan webhookDelivery, webhookQueueSize),
+		done:   make(chan struct{}),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	var wg sync.WaitGroup
+	workers := getEnvInt("WEBHOOK_WORKERS", 4)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range d.queue {
+				deliverWebhook(d.ctx, delivery)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(d.done)
+	}()
+
+	log.Printf("🔔 Webhook dispatcher started (%d workers, allowed hosts: %v)", workers, webhookAllowedHosts)
+	return d
+}
+
+// Stop delivers the queued webhooks and waits for the pool, for
+// shutdown. Deliveries still pending WEBHOOK_SHUTDOWN_GRACE before ctx's
+// deadline are abandoned and recorded as not delivered.
+func (d *webhookDispatcher) Stop(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		abandon := time.AfterFunc(time.Until(deadline)-webhookShutdownGrace, d.cancel)
+		defer abandon.Stop()
+	}
+	defer d.cancel()
+	return waitFor(ctx, d.done)
+}
+
+// enqueueWebhook validates the job's callback URL and queues the result for delivery
+func enqueueWebhook(ctx context.Context, job Job, result *ExecutionResult) {
+	if job.CallbackURL == "" {
+		return
+	}
+
+	if err := validateCallbackURL(job.CallbackURL); err != nil {
+		log.Printf("⚠️  [%s] Webhook rejected: %v", job.JobID, err)
+		recordWebhookOutcome(ctx, job.JobID, false, 0, err.Error())
+		return
+	}
+
+	payload, err := json.Marshal(resultMessage(job, result))
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to marshal webhook payload: %v", job.JobID, err)
+		return
+	}
+
+	d := webhooks
+	if d == nil {
+		return // Disabled: validateCallbackURL has recorded why
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		log.Printf("⚠️  [%s] Webhook dispatcher stopped, callback not delivered", job.JobID)
+		recordWebhookShutdown(job.JobID, 0)
+		return
+	}
+
+	select {
+	case d.queue <- webhookDelivery{jobID: job.JobID, url: job.CallbackURL, payload: payload}:
+	default:
+		log.Printf("⚠️  [%s] Webhook queue full, dropping callback", job.JobID)
+		recordWebhookOutcome(ctx, job.JobID, false, 0, "webhook queue full")
+	}
+}
+
+// validateCallbackURL checks the URL scheme and that its host is allowlisted
+func validateCallbackURL(raw string) error {
+	if len(webhookAllowedHosts) == 0 {
+		return fmt.Errorf("webhooks are disabled")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid callback URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("callback URL scheme must be http or https")
+	}
+	if u.User != nil {
+		return fmt.Errorf("callback URL must not contain credentials")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range webhookAllowedHosts {
+		if host == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("callback host %q is not in WEBHOOK_ALLOWED_HOSTS", host)
+}
+
+// deliverWebhook POSTs the payload, retrying with backoff on failure.
+// Once ctx is cancelled (by shutdown) the delivery is recorded as not delivered.
+func deliverWebhook(ctx context.Context, delivery webhookDelivery) {
+	var lastErr error
+	backoff := 500 * time.Millisecond
+
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		if ctx.Err() != nil {
+			recordWebhookShutdown(delivery.jobID, attempt-1)
+			return
+		}
+		lastErr = postWebhook(ctx, delivery)
+		if lastErr == nil {
+			log.Printf("🔔 [%s] Webhook delivered (attempt %d)", delivery.jobID, attempt)
+			recordWebhookOutcome(ctx, delivery.jobID, true, attempt, "")
+			return
+		}
+
+		if ctx.Err() != nil {
+			recordWebhookShutdown(delivery.jobID, attempt)
+			return
+		}
+		log.Printf("⚠️  [%s] Webhook attempt %d/%d failed: %v", delivery.jobID, attempt, webhookMaxAttempts, lastErr)
+		if attempt == webhookMaxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			recordWebhookShutdown(delivery.jobID, attempt)
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	recordWebhookOutcome(ctx, delivery.jobID, false, webhookMaxAttempts, lastErr.Error())
+}
+
+// postWebhook performs a single delivery attempt
+func postWebhook(ctx context.Context, delivery webhookDelivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", serviceName+"/"+version)
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// recordWebhookOutcome stores the delivery result on the submission document
+func recordWebhookOutcome(ctx context.Context, jobID string, delivered bool, attempts int, errMsg string) {
+	webhook := bson.M{
+		"delivered": delivered,
+		"attempts":  attempts,
+		"updatedAt": time.Now().UTC().Format(time.RFC3339),
+	}
+	if errMsg != "" {
+		webhook["error"] = errMsg
+	}
+
+	_, err := mongoDb.Collection("submissions").UpdateOne(
+		ctx,
+		bson.M{"jobId": jobID},
+		bson.M{"$set": bson.M{"webhook": webhook}},
+	)
+	if err != nil {
+		log.Printf("⚠️  [%s] Failed to record webhook outcome: %v", jobID, err)
+	}
+}
+
+// recordWebhookShutdown records a delivery abandoned because the worker is
+// shutting down, with its own timeout as the caller's context may be gone
+func recordWebhookShutdown(jobID string, attempts int) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookRecordTimeout)
+	defer cancel()
+	recordWebhookOutcome(ctx, jobID, false, attempts, webhookShutdownError)
+}
+
+// parseHostList splits a comma-separated host list, lowercased and trimmed
+func parseHostList(value string) []string {
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
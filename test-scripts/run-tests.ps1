@@ -8,6 +8,48 @@
 #   .\run-tests.ps1 javascript - Test JavaScript execution  
 #   .\run-tests.ps1 timeout    - Test timeout handling
 #   .\run-tests.ps1 error      - Test error handling
+#   .\run-tests.ps1 cpu        - Test CPU-time limit
+#   .\run-tests.ps1 crlf       - Test line-ending normalization
+#   .\run-tests.ps1 rust       - Test Rust compile and run
+#   .\run-tests.ps1 bash       - Test Bash scripts (success and non-zero exit)
+#   .\run-tests.ps1 unbuffered - Test output kept when killed before flushing
+#   .\run-tests.ps1 flood      - Test kill on the output line and byte limits
+#   .\run-tests.ps1 interleave - Test interleaved stdout/stderr ordering
+#   .\run-tests.ps1 fastexit   - Test output of fast-exiting programs is complete
+#   .\run-tests.ps1 idle       - Test idle output timeout
+#   .\run-tests.ps1 redact     - Test code redaction from stored submissions
+#   .\run-tests.ps1 stderr     - Test the stderr-only flag
+#   .\run-tests.ps1 eval       - Test eval mode return values (Python and JavaScript)
+#   .\run-tests.ps1 pullstorm  - Test many jobs arriving for an uncached image
+#   .\run-tests.ps1 cpuset     - Test containers are pinned to CPUSET_CPUS
+#   .\run-tests.ps1 codestdin  - Test code piped via stdin instead of a file
+#   .\run-tests.ps1 noisy      - Test removal of noisy runtime warnings from output
+#   .\run-tests.ps1 swap       - Test a memory swap allowance via MEMORY_SWAP_RATIO_PYTHON
+#   .\run-tests.ps1 events     - Test the executionId and per-run events history
+#   .\run-tests.ps1 statusfail - Test a job still runs when its "processing" status write fails
+#   .\run-tests.ps1 variant    - Test A/B variant selection
+#   .\run-tests.ps1 buildinfo  - Test results record the worker version and config hash
+#   .\run-tests.ps1 analysis   - Test analysis notifications are still published asynchronously
+#   .\run-tests.ps1 lintwarn   - Test a failing lint check with LINT_GATE=warn
+#   .\run-tests.ps1 lintblock  - Test a failing lint check with LINT_GATE=block
+#   .\run-tests.ps1 binary     - Test binary output in base64 and utf8 encodings
+#   .\run-tests.ps1 restart    - Test a restarted worker requeues its own processing list
+#   .\run-tests.ps1 frames     - Test frame-header-like output read back from the container logs
+#   .\run-tests.ps1 aliases    - Test language aliases and names in any case
+#   .\run-tests.ps1 payload    - Test the MAX_PAYLOAD_BYTES limit at and just over it
+#   .\run-tests.ps1 filenames  - Test file name validation and MAX_FILES_PER_JOB
+#   .\run-tests.ps1 quota      - Test the daily quota at its limit and after the counter resets
+#   .\run-tests.ps1 membudget  - Test a profile larger than the whole memory budget
+#   .\run-tests.ps1 dockerversion - Test the Docker API version mismatch diagnostic and DOCKER_API_VERSION pin
+#   .\run-tests.ps1 pinning    - Test a pinned image digest, right or wrong
+#   .\run-tests.ps1 autoremove - Test execution containers with AUTO_REMOVE_CONTAINERS on or off
+#   .\run-tests.ps1 harness    - Test the code harness calling a submitted function
+#   .\run-tests.ps1 multifile  - Test a multi-file submission importing its own modules
+#   .\run-tests.ps1 blank      - Test blank and whitespace-only submissions are rejected without running
+#   .\run-tests.ps1 deflang    - Test jobs without a language, with and without DEFAULT_LANGUAGE
+#   .\run-tests.ps1 stdinparts - Test stdinParts are read concatenated in order
+#   .\run-tests.ps1 segfault   - Test a program killed by SIGSEGV reports the signal
+#   .\run-tests.ps1 stages     - Test compile and setup statuses can't be forged with exit codes
 #   .\run-tests.ps1 all        - Run all tests
 #   .\run-tests.ps1 results    - Check MongoDB results
 # ============================================
@@ -56,6 +98,83 @@ function Submit-Job {
     }
 }
 
+# Queues a job directly, for fields the API gateway doesn't accept yet.
+# Mirrors what POST /submit does: a "queued" document, then the queue push.
+# Override replaces fields of the file's job (a $null value clears one).
+function Push-Job {
+    param(
+        [string]$JsonFile,
+        [hashtable]$Override = @{}
+    )
+
+    $FilePath = Join-Path $ScriptDir $JsonFile
+    if (-not (Test-Path $FilePath)) {
+        Write-Host "Error: File not found: $FilePath" -ForegroundColor Red
+        return
+    }
+
+    $Job = Get-Content $FilePath -Raw | ConvertFrom-Json
+    $Job | Add-Member -NotePropertyName jobId -NotePropertyValue ([guid]::NewGuid().ToString())
+    $Job | Add-Member -NotePropertyName submittedAt -NotePropertyValue ((Get-Date).ToUniversalTime().ToString("o"))
+    foreach ($Key in $Override.Keys) {
+        $Job | Add-Member -NotePropertyName $Key -NotePropertyValue $Override[$Key] -Force
+    }
+    $Json = $Job | ConvertTo-Json -Compress -Depth 10
+
+    try {
+        "db.submissions.insertOne(Object.assign($Json, {status: 'queued'}))" | docker exec -i rce-mongo mongosh --quiet rce-engine | Out-Null
+        $Json | docker exec -i rce-redis redis-cli -x RPUSH submission_queue | Out-Null
+        Write-Host "Job queued directly!" -ForegroundColor Green
+        Write-Host "Job ID: $($Job.jobId)" -ForegroundColor Yellow
+        return $Job.jobId
+    }
+    catch {
+        Write-Host "Error queueing job: $_" -ForegroundColor Red
+    }
+}
+
+function Assert-Result {
+    param(
+        [string]$JobId,
+        [string]$Status,
+        [string]$OutputContains = "",
+        [string]$ErrorContains = "",
+        [hashtable]$Fields = @{}, # Dotted field path -> expected value, e.g. @{'truncation.reason' = 'bytes'}
+        [int]$TimeoutSeconds = 30
+    )
+
+    # Wait for the job to reach a terminal status
+    $Doc = $null
+    $Deadline = (Get-Date).AddSeconds($TimeoutSeconds)
+    do {
+        $Command = "EJSON.stringify(db.submissions.findOne({jobId:'$JobId'}, {_id:0, code:0, files:0}), {relaxed: true})"
+        $Doc = docker exec rce-mongo mongosh --quiet rce-engine --eval $Command | ConvertFrom-Json
+        if ($Doc -and $Doc.status -notin @('queued', 'processing')) { break }
+        Start-Sleep -Seconds 1
+    } while ((Get-Date) -lt $Deadline)
+
+    $Failures = @()
+    if (-not $Doc) {
+        $Failures += "no submission found"
+    } else {
+        if ($Doc.status -ne $Status) { $Failures += "status is '$($Doc.status)', expected '$Status'" }
+        if ($OutputContains -and -not "$($Doc.output)".Contains($OutputContains)) { $Failures += "output doesn't contain '$OutputContains'" }
+        if ($ErrorContains -and -not "$($Doc.error)".Contains($ErrorContains)) { $Failures += "error doesn't contain '$ErrorContains'" }
+        foreach ($Field in $Fields.Keys) {
+            $Value = $Doc
+            foreach ($Name in $Field.Split('.')) { $Value = $Value.$Name }
+            if ("$Value" -ne "$($Fields[$Field])") { $Failures += "$Field is '$Value', expected '$($Fields[$Field])'" }
+        }
+    }
+
+    if ($Failures.Count -eq 0) {
+        Write-Host "PASS [$JobId] $Status" -ForegroundColor Green
+        return
+    }
+    Write-Host "FAIL [$JobId] $($Failures -join '; ')" -ForegroundColor Red
+    if ($Doc) { Write-Host "  output: $($Doc.output)"; Write-Host "  error: $($Doc.error)" }
+}
+
 function Get-Results {
     Write-Header "Latest Submission Results from MongoDB"
     
@@ -76,6 +195,8 @@ function Pull-Images {
     
     docker pull python:3.9-alpine
     docker pull node:18-alpine
+    docker pull rust:1.82-alpine
+    docker pull bash:5.2
     
     Write-Host ""
     Write-Host "Images pulled successfully!" -ForegroundColor Green
@@ -94,6 +215,48 @@ Commands:
   javascript  Submit JavaScript test job (factorial)
   timeout     Submit infinite loop (tests 5s timeout)
   error       Submit code with runtime error
+  cpu         Submit a busy loop (tests CPU-time limit)
+  crlf        Submit mixed line endings (needs NORMALIZE_LINE_ENDINGS=true)
+  rust        Submit a valid Rust program, one with a type error and one slow to compile
+  bash        Submit a Bash script that echoes and one that exits with code 3
+  unbuffered  Submit a print followed by a sleep past the timeout
+  flood       Submit infinite print loops, with and without newlines (tests kill on output limits)
+  interleave  Submit alternating stdout/stderr (needs INTERLEAVE_OUTPUT=true)
+  fastexit    Submit a burst of output followed by an immediate exit, 5 times
+  idle        Submit a print followed by an endless wait (needs IDLE_TIMEOUT=2s)
+  redact      Submit a job and check its stored code (needs PERSIST_CODE=false)
+  stderr      Submit a program that only logs to stderr
+  eval        Queue Python and JavaScript jobs in eval mode and show their return values
+  pullstorm   Remove the Node image, then submit 10 JavaScript jobs at once
+  cpuset      Submit a program printing its allowed CPUs (needs CPUSET_CPUS=0)
+  codestdin   Submit the Python and error jobs with code piped in (needs CODE_DELIVERY=stdin)
+  noisy       Submit a Node program that triggers an ExperimentalWarning
+  swap        Submit a program touching more than its memory limit (needs MEMORY_SWAP_RATIO_PYTHON=2 and host swap)
+  events      Submit a Python job and show its executionId and events history
+  statusfail  Make MongoDB reject the "processing" status write, then submit a Python job
+  variant     Queue jobs with a variant, without one and with an unknown one (needs VARIANTS='{"medium-limits":{"profile":"medium"}}')
+  buildinfo   Submit a Python job and show the workerVersion and configHash it was stored with
+  analysis    Submit 3 jobs while listening on analysis_queue; all 3 notifications should arrive
+  lintwarn    Submit unformatted Python (needs PREPROCESS_PYTHON running black, LINT_GATE=warn)
+  lintblock   Submit unformatted Python (needs PREPROCESS_PYTHON running black, LINT_GATE=block)
+  binary      Queue a program writing raw bytes with outputEncoding base64, then submit it as utf8
+  restart     Park a job in the worker's processing list while it's stopped, then restart it (needs QUEUE_ACK_MODE=at-least-once)
+  frames      Queue a program printing a fake log frame header (needs KILL_ON_OUTPUT_LIMIT=false)
+  aliases     Queue jobs with languages PY, Python3, ' Py ', NODE and an unknown PYTHON4
+  payload     Queue payloads of exactly 4096 bytes and of 4097 (needs MAX_PAYLOAD_BYTES=4096)
+  filenames   Queue jobs with ../ and absolute file names, and 50 and 51 files (default MAX_FILES_PER_JOB=50)
+  quota       Fill today's counter to the limit, then reset it (needs DAILY_EXECUTION_QUOTA=3)
+  membudget   Queue a small and a medium profile job (needs TOTAL_MEMORY_BUDGET_MB=200)
+  dockerversion Run the worker image against a daemon rejecting its API version, without and with DOCKER_API_VERSION
+  pinning     Submit a Python job (needs IMAGE_DIGEST_PYTHON, e.g. the real digest, or sha256: followed by 64 zeros)
+  autoremove  Submit a timing-out job and inspect its container (run with AUTO_REMOVE_CONTAINERS=true and =false)
+  harness     Queue a solution function run through the Python harness, and one that raises
+  multifile   Queue a Python entrypoint importing a package from the same submission
+  blank       Queue jobs whose code is empty, only whitespace, or a whitespace-only entrypoint file
+  deflang     Queue a job with no language and one with an unsupported language (set DEFAULT_LANGUAGE=python, or leave it unset)
+  stdinparts  Queue a program numbering its input lines, given in 4 parts
+  segfault    Queue a Python program that kills itself with SIGSEGV
+  stages      Queue programs exiting with the compile error, setup failure and SIGXCPU exit codes, then a real setup failure
   all         Run all test cases sequentially
   results     Show latest results from MongoDB
   pull        Pre-pull Docker images
@@ -131,6 +294,496 @@ switch ($TestType.ToLower()) {
         Write-Host "This job will cause a ZeroDivisionError..." -ForegroundColor Yellow
         Submit-Job "test-error.json"
     }
+    "cpu" {
+        Write-Header "Testing CPU-Time Limit"
+        Write-Host "This job will spin the CPU and should be killed with SIGXCPU..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-cpu-limit.json"
+        Assert-Result $JobId "cpu_limit_exceeded" -ErrorContains "CPU time limit"
+    }
+    "crlf" {
+        Write-Header "Testing Line-Ending Normalization"
+        Write-Host "Output should use plain newlines; rawOutput keeps the CRLFs..." -ForegroundColor Yellow
+        Submit-Job "test-line-endings.json"
+    }
+    "rust" {
+        Write-Header "Testing Rust Compile and Run"
+        Write-Host "The first job should complete; the second should fail with status compile_error; the third with compile_timeout..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-rust.json"
+        Assert-Result $JobId "completed" -TimeoutSeconds 60
+        $JobId = Submit-Job "test-rust-compile-error.json"
+        Assert-Result $JobId "compile_error" -TimeoutSeconds 60
+        # Under the default profile the compiler hits the 2s CPU time limit before the compile timeout
+        $JobId = Submit-Job "test-rust-slow-compile.json"
+        Assert-Result $JobId "compile_timeout" -ErrorContains "2s CPU time" -TimeoutSeconds 60
+    }
+    "bash" {
+        Write-Header "Testing Bash Execution"
+        Write-Host "The first script should complete; the second should fail with exit code 3..." -ForegroundColor Yellow
+        Submit-Job "test-bash.json"
+        Start-Sleep -Seconds 3
+        Submit-Job "test-bash-exit.json"
+    }
+    "unbuffered" {
+        Write-Header "Testing Unbuffered Output"
+        Write-Host "This job will time out; its output should still contain the line printed before the sleep..." -ForegroundColor Yellow
+        Submit-Job "test-unbuffered.json"
+    }
+    "flood" {
+        Write-Header "Testing Output Limit"
+        Write-Host "This job should be killed well before its timeout with status output_limit_exceeded..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-output-flood.json"
+        Assert-Result $JobId "output_limit_exceeded" -OutputContains "[output truncated: exceeded 10000 lines]" -Fields @{ 'truncation.reason' = 'lines' }
+        $JobId = Submit-Job "test-output-flood-bytes.json"
+        Assert-Result $JobId "output_limit_exceeded" -OutputContains "[output truncated: exceeded 1048576 bytes]" -Fields @{ 'truncation.reason' = 'bytes' }
+    }
+    "interleave" {
+        Write-Header "Testing Interleaved Output"
+        Write-Host "Each error line should follow the prompt it belongs to, not come last..." -ForegroundColor Yellow
+        Submit-Job "test-interleaved.json"
+    }
+    "fastexit" {
+        Write-Header "Testing Fast-Exiting Output"
+        Write-Host "Every run should end with 'Line 2000' and 'Done!'..." -ForegroundColor Yellow
+        for ($i = 1; $i -le 5; $i++) {
+            Submit-Job "test-fast-exit.json"
+        }
+    }
+    "idle" {
+        Write-Header "Testing Idle Output Timeout"
+        Write-Host "This job should be killed after ~2s with status idle_timeout, keeping its first line..." -ForegroundColor Yellow
+        Submit-Job "test-idle.json"
+    }
+    "stderr" {
+        Write-Header "Testing stderr-Only Output"
+        Write-Host "This job should complete with exit code 0 and stderrOnly: true..." -ForegroundColor Yellow
+        Submit-Job "test-stderr-only.json"
+    }
+    "eval" {
+        Write-Header "Testing Eval Mode"
+        Write-Host "Each job should keep its printed line in output and store the last expression as returnValue..." -ForegroundColor Yellow
+        $JobIds = @((Push-Job "test-eval-python.json"), (Push-Job "test-eval-javascript.json"))
+        Start-Sleep -Seconds 5
+        foreach ($JobId in $JobIds) {
+            $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, language:1, status:1, output:1, returnValue:1}))"
+            docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+        }
+    }
+    "pullstorm" {
+        Write-Header "Testing Image Pull Storm"
+        Write-Host "Only one worker should pull node:18-alpine; the others log that they're waiting behind it." -ForegroundColor Yellow
+        Write-Host "Jobs waiting past PULL_WAIT_TIMEOUT are requeued, and all 10 should end up completed..." -ForegroundColor Yellow
+        docker rmi node:18-alpine 2>$null | Out-Null
+        1..10 | ForEach-Object { Submit-Job "test-javascript.json" | Out-Null }
+        Write-Host "`nFollow the pulls with: docker compose logs -f execution-worker" -ForegroundColor Green
+    }
+    "cpuset" {
+        Write-Header "Testing CPU Pinning"
+        Write-Host "The output should list only the cores in CPUSET_CPUS: Allowed CPUs: [0]..." -ForegroundColor Yellow
+        Submit-Job "test-cpuset.json"
+    }
+    "codestdin" {
+        Write-Header "Testing Code Delivery via stdin"
+        Write-Host "The Python job should complete as usual; the error job's traceback should point at File `"<stdin>`"..." -ForegroundColor Yellow
+        Submit-Job "test-python.json"
+        Start-Sleep -Seconds 3
+        Submit-Job "test-error.json"
+        Write-Host "`nWorker logs should show 'Code piped via stdin' and no code written to /tmp/executions" -ForegroundColor Green
+    }
+    "noisy" {
+        Write-Header "Testing Output Filters"
+        Write-Host "output should have no ExperimentalWarning lines; rawOutput should still contain them..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-noisy-output.json"
+        Start-Sleep -Seconds 5
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, output:1, rawOutput:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+    }
+    "swap" {
+        Write-Header "Testing Memory Swap Allowance"
+        Write-Host "The job should complete with 'Touched 160MB'; limits.memorySwapBytes should be twice limits.memoryBytes..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-memory-swap.json"
+        Start-Sleep -Seconds 8
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, exitCode:1, output:1, limits:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+    }
+    "events" {
+        Write-Header "Testing Execution IDs"
+        Write-Host "executionId should be a UUID, matching the single entry in events..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-python.json"
+        Start-Sleep -Seconds 5
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, executionId:1, events:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+        Write-Host "`nThe container label should match: docker ps -a --filter label=rce.execution-id" -ForegroundColor Green
+    }
+    "statusfail" {
+        Write-Header "Testing a Failed Processing Status Write"
+        Write-Host "The worker should log 'Failed to update status to processing, running anyway' and still complete the job..." -ForegroundColor Yellow
+        # A validator that rejects status 'processing' makes only the initial status write fail
+        docker exec rce-mongo mongosh --quiet rce-engine --eval "db.runCommand({collMod: 'submissions', validator: {status: {`$ne: 'processing'}}, validationLevel: 'strict'})" | Out-Null
+        try {
+            $JobId = Submit-Job "test-python.json"
+            Start-Sleep -Seconds 5
+        }
+        finally {
+            docker exec rce-mongo mongosh --quiet rce-engine --eval "db.runCommand({collMod: 'submissions', validator: {}})" | Out-Null
+        }
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, output:1, startedAt:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+        Write-Host "`nstatus should be completed with no startedAt. With PROCESSING_STATUS_FAILURE=requeue the job is requeued until it fails after MAX_JOB_RETRIES instead" -ForegroundColor Green
+    }
+    "variant" {
+        Write-Header "Testing Variant Selection"
+        Write-Host "Expect variant medium-limits with limits.profile medium, control with small, and an unknown variant failed..." -ForegroundColor Yellow
+        $JobIds = @(
+            (Push-Job "test-variant.json"),
+            (Push-Job "test-python.json"),
+            (Push-Job "test-variant-unknown.json")
+        )
+        Start-Sleep -Seconds 8
+        foreach ($JobId in $JobIds) {
+            $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, variant:1, 'limits.profile':1, output:1, error:1}))"
+            docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+        }
+    }
+    "buildinfo" {
+        Write-Header "Testing Worker Version and Config Hash"
+        Write-Host "workerVersion and configHash should match the worker's startup log line 'Worker v..., config hash ...'" -ForegroundColor Yellow
+        $JobId = Submit-Job "test-python.json"
+        Start-Sleep -Seconds 5
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, workerVersion:1, configHash:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+        docker logs rce-execution-worker 2>&1 | Select-String "config hash" | Select-Object -Last 1
+    }
+    "analysis" {
+        Write-Header "Testing Asynchronous Analysis Notifications"
+        Write-Host "Listening on analysis_queue for 20 seconds; each job's notification should arrive..." -ForegroundColor Yellow
+        $Listener = Start-Job { docker exec rce-redis timeout 20 redis-cli SUBSCRIBE analysis_queue }
+        Start-Sleep -Seconds 2
+        $JobIds = @(
+            (Submit-Job "test-python.json"),
+            (Submit-Job "test-javascript.json"),
+            (Submit-Job "test-error.json")
+        )
+        $Messages = ($Listener | Wait-Job | Receive-Job) -join "`n"
+        Remove-Job $Listener
+        foreach ($JobId in $JobIds) {
+            if ($Messages -match [regex]::Escape($JobId)) {
+                Write-Host "Notified: $JobId" -ForegroundColor Green
+            } else {
+                Write-Host "Missing notification: $JobId" -ForegroundColor Red
+            }
+        }
+        Write-Host "`nWith a full queue (e.g. ANALYSIS_NOTIFY_QUEUE_SIZE=1, ANALYSIS_NOTIFY_WORKERS=1) drops are counted in rce_analysis_notifications_dropped_total" -ForegroundColor Green
+    }
+    "lintwarn" {
+        Write-Header "Testing the Lint Gate (warn)"
+        Write-Host "status should be completed with 'Hello, lint'; lint.passed false with black's diff in lint.output..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-lint.json"
+        Start-Sleep -Seconds 10
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, output:1, lint:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+    }
+    "lintblock" {
+        Write-Header "Testing the Lint Gate (block)"
+        Write-Host "status should be lint_failed with no output; lint.blocked true..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-lint.json"
+        Start-Sleep -Seconds 10
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, output:1, error:1, lint:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+    }
+    "binary" {
+        Write-Header "Testing Binary Output"
+        # Pushed directly to keep outputEncoding; the gateway drops it, so the submitted job is utf8
+        $Base64Job = Push-Job "test-binary-output.json"
+        Assert-Result $Base64Job "completed" -Fields @{ output = "AP8NCiAK"; encoding = "base64"; stderr = "warning: binary output`n" }
+        $Utf8Job = Submit-Job "test-binary-output.json"
+        Assert-Result $Utf8Job "completed" -OutputContains ([string][char]0xFFFD) -Fields @{ encoding = "utf8" }
+    }
+    "restart" {
+        Write-Header "Testing Worker Restart Recovery"
+        Write-Host "The job left in the worker's processing list should be requeued at startup and completed..." -ForegroundColor Yellow
+        # In the container the worker is PID 1, so its name survives the restart
+        $Worker = "$(docker exec rce-execution-worker hostname)-1"
+        docker stop rce-execution-worker | Out-Null
+        $JobId = Push-Job "test-python.json"
+        docker exec rce-redis redis-cli LMOVE submission_queue "submission_queue:processing:$Worker" LEFT RIGHT | Out-Null
+        docker start rce-execution-worker | Out-Null
+        Assert-Result $JobId "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+        docker logs rce-execution-worker 2>&1 | Select-String "left by a previous run" | Select-Object -Last 1
+    }
+    "frames" {
+        Write-Header "Testing Frame-Header-Like Output"
+        Write-Host "The fake header should come back byte for byte on stdout, with only the real line on stderr..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-frame-headers.json"
+        Assert-Result $JobId "completed" -Fields @{ output = "AgAAAAAAAARmYWtlCg=="; stderr = "real stderr`n" }
+        Write-Host "`nWorker logs show 'Log demux failed, parsing raw stream' if the fallback parser was used" -ForegroundColor Green
+    }
+    "aliases" {
+        Write-Header "Testing Language Aliases"
+        Write-Host "Aliases and names should resolve in any case and around whitespace; an unknown name still fails..." -ForegroundColor Yellow
+        foreach ($Language in @('PY', 'Python3', ' Py ', 'PYTHON')) {
+            $JobId = Push-Job "test-python.json" -Override @{ language = $Language }
+            Assert-Result $JobId "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+        }
+        $JobId = Push-Job "test-javascript.json" -Override @{ language = 'NODE' }
+        Assert-Result $JobId "completed" -OutputContains "Factorial of 10 is: 3628800"
+        $JobId = Push-Job "test-python.json" -Override @{ language = 'PYTHON4' }
+        Assert-Result $JobId "failed" -ErrorContains "unsupported language: PYTHON4"
+    }
+    "payload" {
+        Write-Header "Testing the Payload Size Limit"
+        Write-Host "A payload of exactly MAX_PAYLOAD_BYTES should run; one byte more is dead-lettered unparsed..." -ForegroundColor Yellow
+        $Limit = 4096
+        # The worker also receives whatever the pipe into redis-cli appends, so measure that first
+        "{}" | docker exec -i rce-redis redis-cli -x SET rce-test:probe | Out-Null
+        $PipeOverhead = [int](docker exec rce-redis redis-cli STRLEN rce-test:probe) - 2
+        docker exec rce-redis redis-cli DEL rce-test:probe | Out-Null
+
+        # Same shape and field lengths as what Push-Job sends
+        $Code = (Get-Content (Join-Path $ScriptDir "test-python.json") -Raw | ConvertFrom-Json).code
+        $Probe = [ordered]@{ language = 'python'; code = $Code; jobId = [guid]::NewGuid().ToString(); submittedAt = (Get-Date).ToUniversalTime().ToString("o") } | ConvertTo-Json -Compress
+        $Padding = $Limit - $PipeOverhead - [Text.Encoding]::UTF8.GetByteCount($Probe) - 3 # "\n#" is 3 bytes once escaped
+
+        $AtLimit = Push-Job "test-python.json" -Override @{ code = $Code + "`n#" + ('x' * $Padding) }
+        Assert-Result $AtLimit "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+
+        $OverLimit = Push-Job "test-python.json" -Override @{ code = $Code + "`n#" + ('x' * ($Padding + 1)) }
+        Start-Sleep -Seconds 3
+        # Never parsed, so its submission stays queued; the dead letter is the only trace
+        $DeadLetter = docker exec rce-redis redis-cli LRANGE submission_queue:dead -1 -1
+        if ("$DeadLetter".Contains("payload of $($Limit + 1) bytes exceeds the $Limit byte limit")) {
+            Write-Host "PASS [$OverLimit] dead-lettered" -ForegroundColor Green
+        } else {
+            Write-Host "FAIL [$OverLimit] no dead letter for a $($Limit + 1) byte payload: $DeadLetter" -ForegroundColor Red
+        }
+    }
+    "filenames" {
+        Write-Header "Testing File Name Validation"
+        Write-Host "Paths escaping the execution directory and too many files should fail before anything runs..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-python.json" -Override @{ files = @{ '../escape.py' = 'print(1)' } }
+        Assert-Result $JobId "failed" -ErrorContains "must not contain '..'"
+        $JobId = Push-Job "test-python.json" -Override @{ files = @{ 'lib/../../escape.py' = 'print(1)' } }
+        Assert-Result $JobId "failed" -ErrorContains "must not contain '..'"
+        $JobId = Push-Job "test-python.json" -Override @{ files = @{ '/etc/escape.py' = 'print(1)' } }
+        Assert-Result $JobId "failed" -ErrorContains "must be a relative path"
+        $JobId = Push-Job "test-python.json" -Override @{ entryPoint = '/tmp/script.py' }
+        Assert-Result $JobId "failed" -ErrorContains "must be a relative path"
+
+        $Files = @{}
+        1..50 | ForEach-Object { $Files["mod$_.py"] = "" }
+        $JobId = Push-Job "test-python.json" -Override @{ files = $Files }
+        Assert-Result $JobId "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+        $Files["mod51.py"] = ""
+        $JobId = Push-Job "test-python.json" -Override @{ files = $Files }
+        Assert-Result $JobId "failed" -ErrorContains "submission has 51 files, limit is 50"
+    }
+    "quota" {
+        Write-Header "Testing the Daily Execution Quota"
+        Write-Host "The 3rd execution of the day should run, the 4th be rejected, and a fresh counter allow runs again..." -ForegroundColor Yellow
+        $Key = "rce:executions:$((Get-Date).ToUniversalTime().ToString('yyyy-MM-dd'))"
+        docker exec rce-redis redis-cli SET $Key 2 | Out-Null
+        try {
+            $JobId = Submit-Job "test-python.json"
+            Assert-Result $JobId "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+            $JobId = Submit-Job "test-python.json"
+            Assert-Result $JobId "quota_exceeded" -ErrorContains "Daily execution quota exceeded"
+
+            $Ttl = [int](docker exec rce-redis redis-cli TTL $Key)
+            if ($Ttl -gt 0 -and $Ttl -le 172800) {
+                Write-Host "PASS $Key expires in $Ttl s" -ForegroundColor Green
+            } else {
+                Write-Host "FAIL $Key TTL is $Ttl, expected 1-172800" -ForegroundColor Red
+            }
+
+            # A new UTC day starts from a fresh counter; deleting today's stands in for it
+            docker exec rce-redis redis-cli DEL $Key | Out-Null
+            $JobId = Submit-Job "test-python.json"
+            Assert-Result $JobId "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+        }
+        finally {
+            docker exec rce-redis redis-cli DEL $Key | Out-Null
+        }
+    }
+    "membudget" {
+        Write-Header "Testing the Host Memory Budget"
+        Write-Host "The small profile (128MB) fits in 200MB and runs; medium (256MB) never can and fails at once..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-python.json"
+        Assert-Result $JobId "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+        $JobId = Push-Job "test-python.json" -Override @{ profile = 'medium' }
+        Assert-Result $JobId "configuration_error" -ErrorContains "memory limit exceeds the host memory budget: 256MB > 200MB"
+        $Reserved = 0
+        foreach ($Key in (docker exec rce-redis redis-cli --scan --pattern 'memory-budget:*')) {
+            $Reserved += [int](docker exec rce-redis redis-cli ZCARD $Key)
+        }
+        if ($Reserved -eq 0) {
+            Write-Host "PASS no memory reservations left after the jobs" -ForegroundColor Green
+        } else {
+            Write-Host "FAIL $Reserved memory reservation(s) still held" -ForegroundColor Red
+        }
+    "dockerversion" {
+        Write-Header "Testing Docker API Version Mismatches"
+        Write-Host "Against a daemon whose minimum API version is above the client's, the worker should refuse to start" -ForegroundColor Yellow
+        Write-Host "naming the version to pin, then start once DOCKER_API_VERSION pins it..." -ForegroundColor Yellow
+        $Image = docker inspect rce-execution-worker --format '{{.Config.Image}}'
+        $Network = docker inspect rce-execution-worker --format '{{range $k, $v := .NetworkSettings.Networks}}{{$k}}{{end}}'
+        # The worker's client speaks API 1.47 at most; this daemon accepts 1.48 and up
+        docker run -d --name rce-test-dind --privileged --network $Network -e DOCKER_TLS_CERTDIR= -e DOCKER_MIN_API_VERSION=1.48 docker:28-dind | Out-Null
+        try {
+            Start-Sleep -Seconds 10
+            $WorkerArgs = @('--network', $Network, '-e', 'DOCKER_HOST=tcp://rce-test-dind:2375',
+                '-e', 'REDIS_URL=redis://redis:6379', '-e', 'MONGO_URL=mongodb://mongo:27017/rce-engine',
+                '-e', 'SUBMISSION_QUEUE=rce-test:version-queue') # Never takes real jobs
+
+            $Log = (docker run --rm @WorkerArgs $Image 2>&1) -join "`n"
+            if ($Log.Contains("Docker API version mismatch") -and $Log.Contains("Set DOCKER_API_VERSION=1.48")) {
+                Write-Host "PASS refused to start with the mismatch diagnostic" -ForegroundColor Green
+            } else {
+                Write-Host "FAIL no mismatch diagnostic:`n$Log" -ForegroundColor Red
+            }
+
+            docker run -d --name rce-test-pinned @WorkerArgs -e DOCKER_API_VERSION=1.48 $Image | Out-Null
+            Start-Sleep -Seconds 5
+            $Log = (docker logs rce-test-pinned 2>&1) -join "`n"
+            $Running = docker inspect rce-test-pinned --format '{{.State.Running}}'
+            if ($Log.Contains("Retrying with Docker API version 1.48") -and $Running -eq 'true') {
+                Write-Host "PASS started pinned to 1.48" -ForegroundColor Green
+            } else {
+                Write-Host "FAIL pinned worker not running:`n$Log" -ForegroundColor Red
+            }
+        }
+        finally {
+            docker rm -f rce-test-pinned rce-test-dind 2>$null | Out-Null
+        }
+    }
+    "pinning" {
+        Write-Header "Testing Image Digest Pinning"
+        $Pin = (docker inspect rce-execution-worker --format '{{range .Config.Env}}{{println .}}{{end}}' | Select-String '^IMAGE_DIGEST_PYTHON=(.+)$').Matches.Groups[1].Value
+        if (-not $Pin) {
+            Write-Host "IMAGE_DIGEST_PYTHON is not set on the worker" -ForegroundColor Red
+            return
+        }
+        $RepoDigests = docker image inspect python:3.9-alpine --format '{{join .RepoDigests " "}}'
+        $JobId = Submit-Job "test-python.json"
+        if ("$RepoDigests".Contains("@$Pin")) {
+            Write-Host "The pin matches the image, so the job should run and record it as imageDigest..." -ForegroundColor Yellow
+            Assert-Result $JobId "completed" -Fields @{ imageDigest = "python@$Pin" }
+        } else {
+            # A local image is never found under a digest it doesn't carry, so a
+            # wrong pin fails closed at the pull, before the local image is verified
+            Write-Host "The pin matches no python image, so the job should fail closed without running..." -ForegroundColor Yellow
+            Assert-Result $JobId "configuration_error" -ErrorContains "language image python@$Pin is not available"
+        }
+    }
+    "autoremove" {
+        Write-Header "Testing Container Auto-Removal"
+        $Setting = (docker inspect rce-execution-worker --format '{{range .Config.Env}}{{println .}}{{end}}' | Select-String '^AUTO_REMOVE_CONTAINERS=(.+)$').Matches.Groups[1].Value
+        $Expected = if ($Setting -eq 'true') { 'true' } else { 'false' }
+        Write-Host "The container should be created with AutoRemove=$Expected, keep its output through the timeout, and be gone afterwards..." -ForegroundColor Yellow
+
+        $JobId = Submit-Job "test-timeout.json"
+        $AutoRemove = $null
+        for ($i = 0; $i -lt 8 -and -not $AutoRemove; $i++) {
+            Start-Sleep -Milliseconds 500
+            $AutoRemove = docker ps -q --filter "label=rce.job-id=$JobId" | ForEach-Object { docker inspect $_ --format '{{.HostConfig.AutoRemove}}' }
+        }
+        if ("$AutoRemove" -eq $Expected) {
+            Write-Host "PASS [$JobId] AutoRemove=$AutoRemove" -ForegroundColor Green
+        } else {
+            Write-Host "FAIL [$JobId] AutoRemove is '$AutoRemove', expected '$Expected'" -ForegroundColor Red
+        }
+
+        Assert-Result $JobId "timeout" -OutputContains "Execution timed out"
+        Start-Sleep -Seconds 2
+        $Left = docker ps -aq --filter "label=rce.job-id=$JobId"
+        if ($Left) {
+            Write-Host "FAIL [$JobId] container $Left was not removed" -ForegroundColor Red
+        } else {
+            Write-Host "PASS [$JobId] container removed" -ForegroundColor Green
+        }
+    }
+    "harness" {
+        Write-Header "Testing the Code Harness"
+        Write-Host "The harness should print solution's return value, and an error should point at the user's own line..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-harness.json"
+        Assert-Result $JobId "completed" -OutputContains "HELLO, HARNESS"
+        $JobId = Push-Job "test-harness.json" -Override @{ code = "def solution(data):`n    return 1 / 0`n" }
+        Assert-Result $JobId "failed" -OutputContains "script.py`", line 2, in solution"
+    }
+    "multifile" {
+        Write-Header "Testing Multi-File Submissions"
+        Write-Host "main.py should import utils/greet.py and print 'Hello, files!' and '3 files'..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-multi-file.json"
+        Assert-Result $JobId "completed" -OutputContains "Hello, files!`n3 files"
+        $JobId = Push-Job "test-multi-file.json" -Override @{ entryPoint = 'missing.py' }
+        Assert-Result $JobId "failed" -ErrorContains "entrypoint `"missing.py`" is not one of the submitted files"
+    }
+    "blank" {
+        Write-Header "Testing Blank Submissions"
+        Write-Host "Each job should fail with 'No code submitted' without starting a container..." -ForegroundColor Yellow
+        foreach ($Code in @('', "   `n`t`n", "`r`n")) {
+            $JobId = Push-Job "test-python.json" -Override @{ code = $Code }
+            Assert-Result $JobId "failed" -ErrorContains "No code submitted"
+        }
+        $JobId = Push-Job "test-multi-file.json" -Override @{ files = @{ 'main.py' = " `n"; 'utils/__init__.py' = 'FILE_COUNT = 1' } }
+        Assert-Result $JobId "failed" -ErrorContains "No code submitted"
+    }
+    "deflang" {
+        Write-Header "Testing the Default Language"
+        $Default = (docker inspect rce-execution-worker --format '{{range .Config.Env}}{{println .}}{{end}}' | Select-String '^DEFAULT_LANGUAGE=(.+)$').Matches.Groups[1].Value
+        if ($Default -and $Default -ne 'python') {
+            Write-Host "DEFAULT_LANGUAGE is $Default; set it to python or leave it unset" -ForegroundColor Red
+            return
+        }
+        $JobId = Push-Job "test-python.json" -Override @{ language = $null }
+        if ($Default -eq 'python') {
+            Write-Host "A job with no language should run as python; an explicit unsupported one still fails..." -ForegroundColor Yellow
+            Assert-Result $JobId "completed" -OutputContains "The sum of numbers 1 to 100 is: 5050"
+        } else {
+            Write-Host "Without DEFAULT_LANGUAGE, a job with no language should fail..." -ForegroundColor Yellow
+            Assert-Result $JobId "failed" -ErrorContains "unsupported language"
+        }
+        $JobId = Push-Job "test-python.json" -Override @{ language = 'cobol' }
+        Assert-Result $JobId "failed" -ErrorContains "unsupported language: cobol"
+    }
+    "stdinparts" {
+        Write-Header "Testing Input in Parts"
+        Write-Host "The parts should read as one input, in order, even when split mid-line..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-stdin-parts.json"
+        Assert-Result $JobId "completed" -Fields @{ output = "0: fixture`n1: case 1`n2: case 2"; stdinConsumed = 'True' }
+        $JobId = Push-Job "test-stdin-parts.json" -Override @{ stdin = "extra`n" }
+        Assert-Result $JobId "failed" -ErrorContains "stdinParts can't be combined with stdin or stdinRef"
+    }
+    "segfault" {
+        Write-Header "Testing Signal Reporting"
+        Write-Host "The program should fail with exit code 139 and signal SIGSEGV..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-segfault.json"
+        Assert-Result $JobId "failed" -OutputContains "about to crash" -ErrorContains "program terminated by SIGSEGV" -Fields @{ signal = 'SIGSEGV'; exitCode = 139 }
+    }
+    "stages" {
+        Write-Header "Testing Stage Reports"
+        Write-Host "Programs exiting 120, 122 or 152 themselves should just fail; a failing setup script should be setup_failed..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-rust.json" -Override @{ code = "fn main() { std::process::exit(120); }" }
+        Assert-Result $JobId "failed" -Fields @{ exitCode = 120 } -TimeoutSeconds 60
+        $JobId = Push-Job "test-rust-compile-error.json"
+        Assert-Result $JobId "compile_error" -ErrorContains "compilation failed" -TimeoutSeconds 60
+        $JobId = Push-Job "test-python.json" -Override @{ code = "import sys`nsys.exit(122)`n"; setupScript = 'true' }
+        Assert-Result $JobId "failed" -Fields @{ exitCode = 122 }
+        $JobId = Push-Job "test-python.json" -Override @{ setupScript = 'echo seeding; exit 3' }
+        Assert-Result $JobId "setup_failed" -OutputContains "seeding"
+        $JobId = Push-Job "test-python.json" -Override @{ code = "import sys`nsys.exit(152)`n" }
+        Assert-Result $JobId "failed" -Fields @{ exitCode = 152 }
+        Write-Host "The program can't read the teardown script, and a forged teardown report stays in its output..." -ForegroundColor Yellow
+        $JobId = Push-Job "test-python.json" -Override @{ code = "import os, sys`nprint(os.environ.get('RCE_TEARDOWN'), os.path.exists('/tmp/.rce-teardown.sh'))`nprint('__RCE_STAGE__ x teardown_failed 1', file=sys.stderr)`n"; teardownScript = 'true' }
+        Assert-Result $JobId "completed" -OutputContains "None False"
+        Assert-Result $JobId "completed" -OutputContains "__RCE_STAGE__ x teardown_failed 1"
+    }
+    "redact" {
+        Write-Header "Testing Code Redaction"
+        Write-Host "The stored document should have output but no code field..." -ForegroundColor Yellow
+        $JobId = Submit-Job "test-python.json"
+        Start-Sleep -Seconds 5
+        $Command = "printjson(db.submissions.findOne({jobId:'$JobId'}, {jobId:1, status:1, output:1, code:1}))"
+        docker exec rce-mongo mongosh --quiet rce-engine --eval $Command
+    }
     "all" {
         Write-Header "Running All Tests"
         